@@ -0,0 +1,391 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultApacheConfigFile is where cPanel keeps the Apache configuration LSWS reads
+const DefaultApacheConfigFile = "/etc/apache2/conf/httpd.conf"
+
+// apacheVhostPrefix prefixes the names LSWS gives the virtual hosts read from
+// the Apache configuration
+const apacheVhostPrefix = "APVH_"
+
+// ApacheConfigCollectorOpts carries the options used in ApacheConfigCollector
+type ApacheConfigCollectorOpts struct {
+	// ConfigFile is the main Apache configuration file, DefaultApacheConfigFile when empty
+	ConfigFile string
+	// Hostnames returns the virtual host names found in the reports the
+	// Apache virtual hosts are matched with, such as LitespeedCollector.Hostnames
+	Hostnames func() []string
+}
+
+var apacheVhostInfo = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "apache_vhost_info"), "Virtual host declared in the Apache configuration, always 1.", []string{"vhost", "domain", "aliases_count", "user"}, nil)
+
+// apacheVhost is a virtual host declared in the Apache configuration, the
+// VirtualHost blocks of the same ServerName being merged
+type apacheVhost struct {
+	serverName string
+	aliases    map[string]bool
+	user       string
+}
+
+// apacheConfigParser holds the state of a parse of the Apache configuration
+type apacheConfigParser struct {
+	serverRoot string
+	vhosts     map[string]*apacheVhost
+	// current is the VirtualHost block being parsed, nil outside of blocks
+	current *apacheVhost
+	// including holds the files being parsed, from the main file to the
+	// current one, so that configurations including themselves don't loop
+	including map[string]bool
+	files     modTimes
+}
+
+// parseApacheConfig returns the virtual hosts declared in an Apache
+// configuration and the files it includes, by lowercased ServerName,
+// recording the files and directories it reads in files
+func parseApacheConfig(fileName string, files modTimes) (map[string]*apacheVhost, error) {
+	p := &apacheConfigParser{
+		// Relative paths are relative to ServerRoot, the directory of the main
+		// file being used until it's declared
+		serverRoot: filepath.Dir(fileName),
+		vhosts:     make(map[string]*apacheVhost),
+		including:  make(map[string]bool),
+		files:      files,
+	}
+	if err := p.parseFile(fileName); err != nil {
+		return nil, err
+	}
+	return p.vhosts, nil
+}
+
+func (p *apacheConfigParser) parseFile(fileName string) error {
+	key, err := filepath.Abs(fileName)
+	if err != nil {
+		return err
+	}
+	if p.including[key] {
+		return fmt.Errorf("%s includes itself", fileName)
+	}
+	p.including[key] = true
+	defer delete(p.including, key)
+
+	p.files.add(fileName)
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line, directive := 0, ""
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		// Directives are continued on the next line by a trailing backslash
+		if strings.HasSuffix(text, "\\") {
+			directive += strings.TrimSuffix(text, "\\") + " "
+			continue
+		}
+		directive += text
+		if err := p.parseDirective(directive); err != nil {
+			return fmt.Errorf("%s:%d: %s", fileName, line, err)
+		}
+		directive = ""
+	}
+	return scanner.Err()
+}
+
+func (p *apacheConfigParser) parseDirective(directive string) error {
+	if directive == "" || strings.HasPrefix(directive, "#") {
+		return nil
+	}
+	args := apacheArgs(directive)
+	name := strings.ToLower(args[0])
+	args = args[1:]
+
+	switch name {
+	case "<virtualhost":
+		p.current = &apacheVhost{aliases: make(map[string]bool)}
+	case "</virtualhost>":
+		p.endVhost()
+	case "serverroot":
+		if len(args) > 0 && p.current == nil {
+			p.serverRoot = args[0]
+		}
+	case "include", "includeoptional":
+		for _, pattern := range args {
+			if err := p.include(pattern, name == "includeoptional"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.current == nil {
+		return nil
+	}
+	switch name {
+	case "servername":
+		if len(args) > 0 {
+			p.current.serverName = stripPort(args[0])
+		}
+	case "serveralias":
+		for _, alias := range args {
+			p.current.aliases[strings.ToLower(alias)] = true
+		}
+	case "suexecusergroup":
+		if len(args) > 0 {
+			p.current.user = args[0]
+		}
+	}
+	return nil
+}
+
+// endVhost merges the VirtualHost block just parsed with the ones of the same
+// ServerName, such as the ones for HTTP and HTTPS
+func (p *apacheConfigParser) endVhost() {
+	vhost := p.current
+	p.current = nil
+	if vhost == nil || vhost.serverName == "" {
+		return
+	}
+
+	key := strings.ToLower(vhost.serverName)
+	existing, ok := p.vhosts[key]
+	if !ok {
+		p.vhosts[key] = vhost
+		return
+	}
+	for alias := range vhost.aliases {
+		existing.aliases[alias] = true
+	}
+	if existing.user == "" {
+		existing.user = vhost.user
+	}
+}
+
+// include parses the files matching an Include pattern, in name order as
+// Apache does. Include fails when nothing matches a pattern without wildcards.
+func (p *apacheConfigParser) include(pattern string, optional bool) error {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(p.serverRoot, pattern)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 && !optional && !strings.ContainsAny(pattern, "*?[") {
+		return fmt.Errorf("included file %s doesn't exist", pattern)
+	}
+	sort.Strings(matches)
+
+	// The directories are watched for the files matching the pattern
+	// later, or the ones missing now
+	if dir := filepath.Dir(pattern); !strings.ContainsAny(dir, "*?[") {
+		p.files.add(dir)
+	}
+	for _, match := range matches {
+		p.files.add(filepath.Dir(match))
+	}
+
+	for _, match := range matches {
+		if fi, err := os.Stat(match); err == nil && fi.IsDir() {
+			// Directories include all their files
+			if err := p.include(filepath.Join(match, "*"), true); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := p.parseFile(match); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apacheArgs splits a directive into its arguments, double quoted arguments
+// keeping their spaces and the > closing a block being dropped
+func apacheArgs(directive string) []string {
+	directive = strings.TrimSpace(directive)
+	if strings.HasPrefix(directive, "<") && !strings.HasPrefix(directive, "</") {
+		directive = strings.TrimSuffix(directive, ">")
+	}
+
+	var args []string
+	var arg strings.Builder
+	quoted, inArg := false, false
+	for _, r := range directive {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case (r == ' ' || r == '\t') && !quoted:
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// stripPort removes the port of a host such as example.com:443
+func stripPort(host string) string {
+	if i := strings.LastIndex(host, ":"); i > 0 && !strings.Contains(host[i:], "]") {
+		if _, err := strconv.Atoi(host[i+1:]); err == nil {
+			return host[:i]
+		}
+	}
+	return host
+}
+
+// apacheServerName returns the ServerName of a virtual host named by LSWS,
+// such as example.com for APVH_example.com:443
+func apacheServerName(hostname string) string {
+	return strings.ToLower(stripPort(strings.TrimPrefix(hostname, apacheVhostPrefix)))
+}
+
+// ApacheConfigCollector exports the domains, aliases and users of the
+// virtual hosts LSWS reads from the Apache configuration of control panels
+// such as cPanel, DirectAdmin or Plesk. The configuration is only read again
+// when one of its files changes.
+type ApacheConfigCollector struct {
+	mutex          sync.Mutex
+	options        ApacheConfigCollectorOpts
+	vhosts         map[string]*apacheVhost
+	files          modTimes
+	scrapeFailures prometheus.Counter
+	// collected and unmatched tell whether the collector already ran, and
+	// whether the reports lacked the virtual host names the last time
+	collected, unmatched bool
+	logger               log.Logger
+}
+
+// NewApacheConfigCollector returns constructed collector
+func NewApacheConfigCollector(opts ApacheConfigCollectorOpts, logger log.Logger) *ApacheConfigCollector {
+	if opts.ConfigFile == "" {
+		opts.ConfigFile = DefaultApacheConfigFile
+	}
+	if opts.Hostnames == nil {
+		opts.Hostnames = func() []string { return nil }
+	}
+
+	return &ApacheConfigCollector{
+		options: opts,
+		scrapeFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "apache_config",
+			Name:      "scrape_failures_total",
+			Help:      "Number of errors while reading the Apache configuration.",
+		}),
+		logger: logger,
+	}
+}
+
+// Describe describes all the metrics that can be exported by the Apache configuration collector
+func (c *ApacheConfigCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- apacheVhostInfo
+	ch <- c.scrapeFailures.Desc()
+}
+
+// Collect reads the Apache configuration and delivers its virtual hosts as Prometheus metrics
+func (c *ApacheConfigCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.files == nil || c.files.changed() {
+		c.reload()
+	}
+	c.collectVhosts(ch)
+	ch <- c.scrapeFailures
+}
+
+// reload reads the configuration again, the previous virtual hosts being
+// kept when it can't be read
+func (c *ApacheConfigCollector) reload() {
+	c.files = modTimes{}
+	vhosts, err := parseApacheConfig(c.options.ConfigFile, c.files)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "Can't read Apache configuration", "err", err)
+		c.scrapeFailures.Inc()
+		return
+	}
+	c.vhosts = vhosts
+}
+
+// collectVhosts exports every virtual host under the names its ServerName or
+// aliases are found with in the reports, or under the name LSWS gives it when
+// it's not found
+func (c *ApacheConfigCollector) collectVhosts(ch chan<- prometheus.Metric) {
+	keys := make(map[string]string)
+	for key, vhost := range c.vhosts {
+		for alias := range vhost.aliases {
+			keys[alias] = key
+		}
+	}
+	// ServerNames win over the aliases of other virtual hosts
+	for key := range c.vhosts {
+		keys[key] = key
+	}
+
+	hostnames := c.options.Hostnames()
+	c.logUnmatched(len(hostnames) == 0)
+
+	names := make(map[string][]string)
+	for _, hostname := range hostnames {
+		if key, ok := keys[apacheServerName(hostname)]; ok {
+			names[key] = append(names[key], hostname)
+		}
+	}
+
+	for key, vhost := range c.vhosts {
+		vhostNames, ok := names[key]
+		if !ok {
+			vhostNames = []string{apacheVhostPrefix + vhost.serverName}
+		}
+		for _, name := range vhostNames {
+			ch <- prometheus.MustNewConstMetric(apacheVhostInfo, prometheus.GaugeValue, 1, name, vhost.serverName, strconv.Itoa(len(vhost.aliases)), vhost.user)
+		}
+	}
+}
+
+// logUnmatched warns when the reports lack the virtual host names, which
+// requires --litespeed.req-rates-by-host, every virtual host being exported
+// under the name derived from its ServerName. The reports may not have been
+// scraped yet the first time.
+func (c *ApacheConfigCollector) logUnmatched(unmatched bool) {
+	if !c.collected {
+		c.collected = true
+		return
+	}
+
+	switch {
+	case unmatched && !c.unmatched:
+		level.Warn(c.logger).Log("msg", "No virtual host found in the reports, exporting Apache virtual hosts as APVH_ followed by their ServerName")
+	case !unmatched && c.unmatched:
+		level.Info(c.logger).Log("msg", "Virtual hosts found in the reports again, matching them with Apache virtual hosts")
+	}
+	c.unmatched = unmatched
+}
@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseApacheConfig(t *testing.T) {
+	files := modTimes{}
+	vhosts, err := parseApacheConfig(path.Join("..", "testdata", "apache", "httpd.conf"), files)
+
+	assert.Nil(t, err)
+	assert.Len(t, vhosts, 2)
+
+	example := vhosts["example.com"]
+	if assert.NotNil(t, example) {
+		assert.Equal(t, "example.com", example.serverName)
+		assert.Equal(t, "example", example.user)
+		assert.Equal(t, map[string]bool{
+			"www.example.com":    true,
+			"mail.example.com":   true,
+			"shop.example.com":   true,
+			"secure.example.com": true,
+		}, example.aliases)
+	}
+
+	blog := vhosts["blog.example.net"]
+	if assert.NotNil(t, blog) {
+		assert.Equal(t, "blogger", blog.user)
+		assert.Len(t, blog.aliases, 0)
+	}
+
+	// The included files are watched along with their directories
+	assert.Contains(t, files, path.Join("..", "testdata", "apache", "conf.d", "blog.conf"))
+	assert.Contains(t, files, path.Join("..", "testdata", "apache", "conf.d"))
+	assert.False(t, files.changed())
+}
+
+func TestParseApacheConfigErrors(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "TestParseApacheConfigErrors")
+	defer os.RemoveAll(dir)
+
+	missing := path.Join(dir, "missing.conf")
+	ioutil.WriteFile(missing, []byte("Include conf.d/missing.conf\n"), 0644)
+	_, err := parseApacheConfig(missing, modTimes{})
+	assert.Error(t, err)
+
+	loop := path.Join(dir, "loop.conf")
+	ioutil.WriteFile(loop, []byte("Include loop.conf\n"), 0644)
+	_, err = parseApacheConfig(loop, modTimes{})
+	assert.EqualError(t, err, loop+":1: "+loop+" includes itself")
+
+	// Files included more than once without looping are parsed every time
+	ioutil.WriteFile(path.Join(dir, "suexec.conf"), []byte("SuexecUserGroup shared shared\n"), 0644)
+	twice := path.Join(dir, "twice.conf")
+	ioutil.WriteFile(twice, []byte(`<VirtualHost *:80>
+ServerName a.com
+Include suexec.conf
+</VirtualHost>
+<VirtualHost *:80>
+ServerName b.com
+Include suexec.conf
+</VirtualHost>
+`), 0644)
+	vhosts, err := parseApacheConfig(twice, modTimes{})
+	assert.Nil(t, err)
+	assert.Equal(t, "shared", vhosts["a.com"].user)
+	assert.Equal(t, "shared", vhosts["b.com"].user)
+}
+
+func TestApacheArgs(t *testing.T) {
+	tests := []struct {
+		directive string
+		want      []string
+	}{
+		{"ServerName example.com", []string{"ServerName", "example.com"}},
+		{"ServerAlias  www.example.com\tmail.example.com", []string{"ServerAlias", "www.example.com", "mail.example.com"}},
+		{`Include "/etc/apache2/conf.d/user data/*.conf"`, []string{"Include", "/etc/apache2/conf.d/user data/*.conf"}},
+		{"<VirtualHost 192.0.2.1:80 [2001:db8::1]:80>", []string{"<VirtualHost", "192.0.2.1:80", "[2001:db8::1]:80"}},
+		{"</VirtualHost>", []string{"</VirtualHost>"}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, apacheArgs(tt.directive), tt.directive)
+	}
+}
+
+func TestApacheServerName(t *testing.T) {
+	tests := map[string]string{
+		"APVH_example.com":     "example.com",
+		"APVH_Example.com:443": "example.com",
+		"example.com":          "example.com",
+		"[2001:db8::1]":        "[2001:db8::1]",
+		"[2001:db8::1]:8080":   "[2001:db8::1]",
+	}
+
+	for hostname, want := range tests {
+		assert.Equal(t, want, apacheServerName(hostname), hostname)
+	}
+}
+
+func TestApacheConfigCollector(t *testing.T) {
+	c := NewApacheConfigCollector(
+		ApacheConfigCollectorOpts{
+			ConfigFile: path.Join("..", "testdata", "apache", "httpd.conf"),
+			Hostnames: func() []string {
+				return []string{"APVH_example.com", "APVH_example.com:443", "APVH_www.example.com", "APVH_unknown.com", "localhost"}
+			},
+		},
+		log.NewNopLogger(),
+	)
+
+	expected := `
+# HELP litespeed_apache_vhost_info Virtual host declared in the Apache configuration, always 1.
+# TYPE litespeed_apache_vhost_info gauge
+litespeed_apache_vhost_info{aliases_count="0",domain="blog.example.net",user="blogger",vhost="APVH_blog.example.net"} 1
+litespeed_apache_vhost_info{aliases_count="4",domain="example.com",user="example",vhost="APVH_example.com"} 1
+litespeed_apache_vhost_info{aliases_count="4",domain="example.com",user="example",vhost="APVH_example.com:443"} 1
+litespeed_apache_vhost_info{aliases_count="4",domain="example.com",user="example",vhost="APVH_www.example.com"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "litespeed_apache_vhost_info"); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.scrapeFailures))
+}
+
+func TestApacheConfigCollectorMissingConfig(t *testing.T) {
+	c := NewApacheConfigCollector(ApacheConfigCollectorOpts{ConfigFile: path.Join("..", "testdata", "non-existing-file")}, log.NewNopLogger())
+
+	assert.Equal(t, 1, testutil.CollectAndCount(c))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.scrapeFailures))
+}
+
+func TestApacheConfigCollectorReloadsChangedConfig(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "TestApacheConfigCollectorReloadsChangedConfig")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(path.Join(dir, "httpd.conf"), []byte("IncludeOptional conf.d/*.conf\n"), 0644)
+
+	c := NewApacheConfigCollector(ApacheConfigCollectorOpts{ConfigFile: path.Join(dir, "httpd.conf")}, log.NewNopLogger())
+	assert.Equal(t, 1, testutil.CollectAndCount(c))
+
+	// Files added to the directories of the included ones are found
+	os.Mkdir(path.Join(dir, "conf.d"), 0755)
+	ioutil.WriteFile(path.Join(dir, "conf.d", "example.conf"), []byte("<VirtualHost *:80>\nServerName example.com\n</VirtualHost>\n"), 0644)
+	expected := `
+# HELP litespeed_apache_vhost_info Virtual host declared in the Apache configuration, always 1.
+# TYPE litespeed_apache_vhost_info gauge
+litespeed_apache_vhost_info{aliases_count="0",domain="example.com",user="",vhost="APVH_example.com"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "litespeed_apache_vhost_info"); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+
+	// Unchanged configurations aren't read again
+	assert.False(t, c.files.changed())
+
+	// The previous virtual hosts are kept when the configuration can't be read
+	os.RemoveAll(dir)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "litespeed_apache_vhost_info"); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.scrapeFailures))
+}
@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"net"
+	"sort"
+	"strings"
+)
+
+// maxBlockedIPSeries is the hard cap on the number of labelled blocked IP
+// series exported per core, regardless of the configured top N.
+const maxBlockedIPSeries = 100
+
+type blockedIPCount struct {
+	Address string
+	Count   float64
+}
+
+// blockedIPKey returns the address an entry is grouped by. Entries may carry
+// extra attributes after a semicolon, which are dropped. With byPrefix, IPv4
+// addresses are grouped by /24 and IPv6 addresses by /64.
+func blockedIPKey(entry string, byPrefix bool) string {
+	address := strings.SplitN(entry, ";", 2)[0]
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return address
+	}
+
+	if !byPrefix {
+		return ip.String()
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// topBlockedIPs counts the blocked entries by address or prefix and returns the
+// n largest groups, never more than maxBlockedIPSeries.
+func topBlockedIPs(entries []string, n int, byPrefix bool) []blockedIPCount {
+	if n > maxBlockedIPSeries {
+		n = maxBlockedIPSeries
+	}
+
+	counts := make(map[string]float64)
+	for _, entry := range entries {
+		counts[blockedIPKey(entry, byPrefix)]++
+	}
+
+	top := make([]blockedIPCount, 0, len(counts))
+	for address, count := range counts {
+		top = append(top, blockedIPCount{Address: address, Count: count})
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Address < top[j].Address
+	})
+
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
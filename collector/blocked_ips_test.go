@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockedIPKeyReturnsExpected(t *testing.T) {
+	tests := []struct {
+		entry    string
+		byPrefix bool
+		e        string
+	}{
+		{"192.0.2.1", false, "192.0.2.1"},
+		{"192.0.2.1;T", false, "192.0.2.1"},
+		{"192.0.2.1", true, "192.0.2.0/24"},
+		{"2001:db8:1:2:3::1", true, "2001:db8:1:2::/64"},
+		{"not-an-ip", true, "not-an-ip"},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.e, blockedIPKey(tc.entry, tc.byPrefix))
+	}
+}
+
+func TestTopBlockedIPsReturnsLargestGroups(t *testing.T) {
+	entries := []string{"192.0.2.1", "192.0.2.2", "192.0.2.2", "198.51.100.7", "203.0.113.9", "203.0.113.10", "203.0.113.11"}
+
+	assert.Equal(t, []blockedIPCount{
+		{Address: "192.0.2.2", Count: 2},
+		{Address: "192.0.2.1", Count: 1},
+	}, topBlockedIPs(entries, 2, false))
+
+	assert.Equal(t, []blockedIPCount{
+		{Address: "192.0.2.0/24", Count: 3},
+		{Address: "203.0.113.0/24", Count: 3},
+		{Address: "198.51.100.0/24", Count: 1},
+	}, topBlockedIPs(entries, 5, true))
+}
+
+func TestTopBlockedIPsEnforcesHardCap(t *testing.T) {
+	var entries []string
+	for i := 0; i < 2*maxBlockedIPSeries; i++ {
+		entries = append(entries, fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+
+	assert.Len(t, topBlockedIPs(entries, 10*maxBlockedIPSeries, false), maxBlockedIPSeries)
+}
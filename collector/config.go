@@ -0,0 +1,362 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultServerRoot is where LiteSpeed is installed by default
+const DefaultServerRoot = "/usr/local/lsws"
+
+// ConfigCollectorOpts carries the options used in ConfigCollector
+type ConfigCollectorOpts struct {
+	// ServerRoot is where LiteSpeed is installed, DefaultServerRoot when empty
+	ServerRoot string
+}
+
+var (
+	configVhostInfo    = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "vhost_info"), "Virtual host declared in the LiteSpeed configuration, always 1.", []string{"vhost", "docroot", "user"}, nil)
+	configListenerInfo = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "listener_info"), "Listener declared in the LiteSpeed configuration, always 1.", []string{"name", "address", "secure"}, nil)
+)
+
+// extappLimit describes how a limit of the external applications is exported
+type extappLimit struct {
+	desc *prometheus.Desc
+	// bytes parses sizes such as 2047M
+	bytes bool
+}
+
+// extappLimits are the external application limits exported by parameter name
+var extappLimits = map[string]extappLimit{
+	"maxConns":      {desc: newExtappLimitDesc("max_connections", "Maximum number of connections to the external application configured.")},
+	"instances":     {desc: newExtappLimitDesc("instances", "Number of instances of the external application configured.")},
+	"memSoftLimit":  {desc: newExtappLimitDesc("memory_soft_limit_bytes", "Soft limit of the memory of the external application processes configured."), bytes: true},
+	"memHardLimit":  {desc: newExtappLimitDesc("memory_hard_limit_bytes", "Hard limit of the memory of the external application processes configured."), bytes: true},
+	"procSoftLimit": {desc: newExtappLimitDesc("process_soft_limit", "Soft limit of the number of processes of the external application user configured.")},
+	"procHardLimit": {desc: newExtappLimitDesc("process_hard_limit", "Hard limit of the number of processes of the external application user configured.")},
+}
+
+func newExtappLimitDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "extapp_config", name), help, []string{"vhost", "handler"}, nil)
+}
+
+// vhostConfig is a virtual host declared in the configuration
+type vhostConfig struct {
+	name, docRoot, user string
+}
+
+// listenerConfig is a listener declared in the configuration
+type listenerConfig struct {
+	name, address string
+	secure        bool
+}
+
+// extappConfig is an external application declared in the configuration,
+// vhost being empty for the ones declared at server level
+type extappConfig struct {
+	vhost, name string
+	limits      map[string]float64
+}
+
+// litespeedConfig is what the exporter reads from the LiteSpeed configuration
+type litespeedConfig struct {
+	vhosts    []vhostConfig
+	listeners []listenerConfig
+	extApps   []extappConfig
+	// errors are the virtual host configurations that couldn't be read, the
+	// virtual hosts being exported without their docroot and applications
+	errors []error
+}
+
+// loadConfig reads the configuration under serverRoot, conf/httpd_config.xml
+// for LSWS and conf/httpd_config.conf for OpenLiteSpeed, recording the files
+// it reads in files
+func loadConfig(serverRoot string, files modTimes) (*litespeedConfig, error) {
+	// Variables such as $VH_ROOT must expand to absolute paths
+	serverRoot, err := filepath.Abs(serverRoot)
+	if err != nil {
+		return nil, err
+	}
+	parse := func(fileName string) (*configBlock, error) {
+		files.add(fileName)
+		return parseConfigFile(fileName)
+	}
+
+	fileName := filepath.Join(serverRoot, "conf", "httpd_config.xml")
+	if _, err := os.Stat(fileName); err != nil {
+		// The LSWS configuration taking precedence once created
+		files.add(fileName)
+		fileName = filepath.Join(serverRoot, "conf", "httpd_config.conf")
+	}
+	root, err := parse(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &litespeedConfig{}
+	vars := map[string]string{"SERVER_ROOT": serverRoot}
+
+	for _, block := range root.children("listener") {
+		cfg.listeners = append(cfg.listeners, listenerConfig{
+			name:    block.name,
+			address: block.params["address"],
+			secure:  block.params["secure"] == "1",
+		})
+	}
+	cfg.addExtApps("", root, vars)
+
+	for _, block := range root.children("virtualhost") {
+		vhostVars := withVars(vars, "VH_NAME", block.name)
+		vhRoot := resolveConfigPath(block.params["vhRoot"], serverRoot, vhostVars)
+		vhostVars = withVars(vhostVars, "VH_ROOT", vhRoot)
+
+		vhost := vhostConfig{name: block.name}
+		vhconf, err := parse(resolveConfigPath(block.params["configFile"], serverRoot, vhostVars))
+		if err != nil {
+			cfg.errors = append(cfg.errors, err)
+			cfg.vhosts = append(cfg.vhosts, vhost)
+			continue
+		}
+		cfg.addVhost(vhost, vhconf, vhRoot, vhostVars)
+	}
+
+	for _, block := range root.children("vhtemplate") {
+		template, err := parse(resolveConfigPath(block.params["templateFile"], serverRoot, vars))
+		if err != nil {
+			cfg.errors = append(cfg.errors, err)
+			continue
+		}
+
+		for _, member := range block.children("member") {
+			vhostVars := withVars(vars, "VH_NAME", member.name)
+			vhRoot := member.params["vhRoot"]
+			if vhRoot == "" {
+				vhRoot = template.params["vhRoot"]
+			}
+			vhRoot = resolveConfigPath(vhRoot, serverRoot, vhostVars)
+			vhostVars = withVars(vhostVars, "VH_ROOT", vhRoot)
+
+			cfg.addVhost(vhostConfig{name: member.name}, template.child("virtualhostconfig"), vhRoot, vhostVars)
+		}
+	}
+
+	return cfg, nil
+}
+
+// addVhost adds a virtual host along with its external applications, the
+// user being the one they run as
+func (cfg *litespeedConfig) addVhost(vhost vhostConfig, vhconf *configBlock, vhRoot string, vars map[string]string) {
+	vhost.docRoot = resolveConfigPath(vhconf.params["docRoot"], vhRoot, vars)
+	for _, block := range vhconf.children("extprocessor") {
+		if user := block.params["extUser"]; user != "" {
+			vhost.user = expandConfigVars(user, vars)
+			break
+		}
+	}
+	cfg.vhosts = append(cfg.vhosts, vhost)
+	cfg.addExtApps(vhost.name, vhconf, vars)
+}
+
+func (cfg *litespeedConfig) addExtApps(vhost string, block *configBlock, vars map[string]string) {
+	for _, extprocessor := range block.children("extprocessor") {
+		ea := extappConfig{vhost: vhost, name: expandConfigVars(extprocessor.name, vars), limits: make(map[string]float64)}
+		for param, limit := range extappLimits {
+			value, ok := extprocessor.params[param]
+			if !ok {
+				continue
+			}
+			if v, err := parseConfigValue(value, limit.bytes); err == nil {
+				ea.limits[param] = v
+			}
+		}
+		cfg.extApps = append(cfg.extApps, ea)
+	}
+}
+
+func withVars(vars map[string]string, name, value string) map[string]string {
+	m := make(map[string]string, len(vars)+1)
+	for k, v := range vars {
+		m[k] = v
+	}
+	m[name] = value
+	return m
+}
+
+// expandConfigVars replaces the variables such as $VH_ROOT
+func expandConfigVars(s string, vars map[string]string) string {
+	for _, name := range []string{"SERVER_ROOT", "VH_ROOT", "VH_NAME"} {
+		if value, ok := vars[name]; ok {
+			s = strings.Replace(s, "$"+name, value, -1)
+		}
+	}
+	return s
+}
+
+// resolveConfigPath expands the variables of a path, relative paths being
+// relative to base
+func resolveConfigPath(p, base string, vars map[string]string) string {
+	if p == "" {
+		return ""
+	}
+	p = expandConfigVars(p, vars)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(base, p)
+	}
+	return filepath.Clean(p)
+}
+
+// parseConfigValue parses a number, sizes such as 2047M being converted to bytes
+func parseConfigValue(value string, bytes bool) (float64, error) {
+	multiplier := 1.0
+	if bytes && value != "" {
+		switch strings.ToUpper(value[len(value)-1:]) {
+		case "K":
+			multiplier = 1 << 10
+		case "M":
+			multiplier = 1 << 20
+		case "G":
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			value = value[:len(value)-1]
+		}
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	return v * multiplier, nil
+}
+
+// ConfigCollector exports the virtual hosts, listeners and external
+// application limits declared in the LiteSpeed configuration, so that
+// virtual hosts without traffic can be told apart from missing ones. The
+// configuration is only read again when one of its files changes.
+type ConfigCollector struct {
+	mutex          sync.Mutex
+	options        ConfigCollectorOpts
+	config         *litespeedConfig
+	files          modTimes
+	scrapeFailures prometheus.Counter
+	lastReload     prometheus.Gauge
+	parseErrors    prometheus.Gauge
+	logger         log.Logger
+}
+
+// NewConfigCollector returns constructed collector
+func NewConfigCollector(opts ConfigCollectorOpts, logger log.Logger) *ConfigCollector {
+	if opts.ServerRoot == "" {
+		opts.ServerRoot = DefaultServerRoot
+	}
+
+	return &ConfigCollector{
+		options: opts,
+		scrapeFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "config",
+			Name:      "scrape_failures_total",
+			Help:      "Number of errors while reading the LiteSpeed configuration.",
+		}),
+		lastReload: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "config",
+			Name:      "last_reload_success_timestamp_seconds",
+			Help:      "Time the LiteSpeed configuration was last read, since unix epoch in seconds.",
+		}),
+		parseErrors: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "config",
+			Name:      "parse_errors",
+			Help:      "Number of configuration files that couldn't be read during the last reload.",
+		}),
+		logger: logger,
+	}
+}
+
+// Describe describes all the metrics that can be exported by the configuration collector
+func (c *ConfigCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- configVhostInfo
+	ch <- configListenerInfo
+	for _, limit := range extappLimits {
+		ch <- limit.desc
+	}
+	ch <- c.scrapeFailures.Desc()
+	ch <- c.lastReload.Desc()
+	ch <- c.parseErrors.Desc()
+}
+
+// Collect reads the LiteSpeed configuration and delivers it as Prometheus metrics
+func (c *ConfigCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.files == nil || c.files.changed() {
+		c.reload()
+	}
+	if c.config != nil {
+		c.collectConfig(c.config, ch)
+	}
+	ch <- c.scrapeFailures
+	ch <- c.lastReload
+	ch <- c.parseErrors
+}
+
+// reload reads the configuration again, the previous one being kept when
+// the main file can't be read
+func (c *ConfigCollector) reload() {
+	c.files = modTimes{}
+	cfg, err := loadConfig(c.options.ServerRoot, c.files)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "Can't read LiteSpeed configuration", "err", err)
+		c.scrapeFailures.Inc()
+		c.parseErrors.Set(1)
+		return
+	}
+	for _, err := range cfg.errors {
+		level.Warn(c.logger).Log("msg", "Can't read virtual host configuration", "err", err)
+	}
+	if len(cfg.errors) > 0 {
+		c.scrapeFailures.Inc()
+	}
+
+	c.config = cfg
+	c.lastReload.SetToCurrentTime()
+	c.parseErrors.Set(float64(len(cfg.errors)))
+}
+
+func (c *ConfigCollector) collectConfig(cfg *litespeedConfig, ch chan<- prometheus.Metric) {
+	// Names declared twice, such as a template member also declared as a
+	// virtual host, are only exported once, the first declaration winning
+	seen := make(map[string]bool)
+	for _, vhost := range cfg.vhosts {
+		if seen["vhost\xff"+vhost.name] {
+			continue
+		}
+		seen["vhost\xff"+vhost.name] = true
+		ch <- prometheus.MustNewConstMetric(configVhostInfo, prometheus.GaugeValue, 1, vhost.name, vhost.docRoot, vhost.user)
+	}
+	for _, listener := range cfg.listeners {
+		if seen["listener\xff"+listener.name] {
+			continue
+		}
+		seen["listener\xff"+listener.name] = true
+		ch <- prometheus.MustNewConstMetric(configListenerInfo, prometheus.GaugeValue, 1, listener.name, listener.address, strconv.FormatBool(listener.secure))
+	}
+	for _, ea := range cfg.extApps {
+		if seen["extapp\xff"+ea.vhost+"\xff"+ea.name] {
+			continue
+		}
+		seen["extapp\xff"+ea.vhost+"\xff"+ea.name] = true
+		for param, value := range ea.limits {
+			ch <- prometheus.MustNewConstMetric(extappLimits[param].desc, prometheus.GaugeValue, value, ea.vhost, ea.name)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configBlock is a block of a LiteSpeed configuration, such as a virtual
+// host or a listener, OpenLiteSpeed and LSWS configurations being parsed
+// into the same blocks. Kinds are lowercased, parameter names are kept as is.
+type configBlock struct {
+	kind, name string
+	params     map[string]string
+	blocks     []*configBlock
+}
+
+func newConfigBlock(kind, name string) *configBlock {
+	return &configBlock{kind: strings.ToLower(kind), name: name, params: make(map[string]string)}
+}
+
+// children returns the blocks of the given kind
+func (b *configBlock) children(kind string) []*configBlock {
+	var blocks []*configBlock
+	for _, child := range b.blocks {
+		if child.kind == kind {
+			blocks = append(blocks, child)
+		}
+	}
+	return blocks
+}
+
+// child returns the first block of the given kind, an empty one when missing
+func (b *configBlock) child(kind string) *configBlock {
+	if blocks := b.children(kind); len(blocks) > 0 {
+		return blocks[0]
+	}
+	return newConfigBlock(kind, "")
+}
+
+// parseConfigFile parses an OpenLiteSpeed configuration, or an LSWS one when
+// its name ends with .xml
+func parseConfigFile(fileName string) (*configBlock, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var root *configBlock
+	if strings.EqualFold(filepath.Ext(fileName), ".xml") {
+		root, err = parseXMLConfig(f)
+	} else {
+		root, err = parseOLSConfig(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't parse %s: %s", fileName, err)
+	}
+	return root, nil
+}
+
+// parseOLSConfig parses the "key value" lines and "kind name { ... }" blocks
+// of an OpenLiteSpeed configuration
+func parseOLSConfig(r io.Reader) (*configBlock, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	root := newConfigBlock("", "")
+	stack := []*configBlock{root}
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		current := stack[len(stack)-1]
+
+		if text == "}" {
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("line %d: unexpected }", line)
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		if strings.HasSuffix(text, "{") {
+			fields := strings.Fields(strings.TrimSuffix(text, "{"))
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("line %d: block without kind", line)
+			}
+			block := newConfigBlock(fields[0], strings.Join(fields[1:], " "))
+			current.blocks = append(current.blocks, block)
+			stack = append(stack, block)
+			continue
+		}
+
+		key, value := text, ""
+		if i := strings.IndexAny(text, " \t"); i > 0 {
+			key, value = text[:i], strings.TrimSpace(text[i:])
+		}
+
+		// Values such as rewrite rules span lines up to the given marker
+		if strings.HasPrefix(value, "<<<") {
+			marker := strings.TrimPrefix(value, "<<<")
+			var lines []string
+			for {
+				if !scanner.Scan() {
+					return nil, fmt.Errorf("line %d: missing %s", line, marker)
+				}
+				line++
+				if strings.TrimSpace(scanner.Text()) == marker {
+					break
+				}
+				lines = append(lines, scanner.Text())
+			}
+			value = strings.Join(lines, "\n")
+		}
+
+		// Template members are also declared without block
+		if key == "member" {
+			current.blocks = append(current.blocks, newConfigBlock(key, value))
+			continue
+		}
+		current.params[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("missing } for %s %s", stack[len(stack)-1].kind, stack[len(stack)-1].name)
+	}
+	return root, nil
+}
+
+// parseXMLConfig parses an LSWS configuration, elements holding text being
+// parameters and the others blocks. The elements listing blocks, such as
+// virtualHostList, are left out so that blocks end up where they would in an
+// OpenLiteSpeed configuration.
+func parseXMLConfig(r io.Reader) (*configBlock, error) {
+	decoder := xml.NewDecoder(r)
+
+	root := newConfigBlock("", "")
+	stack := []*configBlock{root}
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, newConfigBlock(t.Name.Local, ""))
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			block, parent := stack[len(stack)-1], stack[len(stack)-2]
+			stack = stack[:len(stack)-1]
+
+			switch {
+			case len(block.blocks) == 0 && len(block.params) == 0:
+				parent.params[t.Name.Local] = strings.TrimSpace(text.String())
+			case strings.HasSuffix(block.kind, "list"):
+				parent.blocks = append(parent.blocks, block.blocks...)
+			default:
+				block.name = block.params["name"]
+				if block.kind == "member" {
+					block.name = block.params["vhName"]
+				}
+				parent.blocks = append(parent.blocks, block)
+			}
+			text.Reset()
+		}
+	}
+
+	// The root element, such as httpServerConfig, holds the configuration
+	if len(root.blocks) != 1 {
+		return nil, fmt.Errorf("expected a single root element")
+	}
+	root.blocks[0].kind, root.blocks[0].name = "", ""
+	return root.blocks[0], nil
+}
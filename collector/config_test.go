@@ -0,0 +1,214 @@
+package collector
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOLSConfig(t *testing.T) {
+	root, err := parseConfigFile(path.Join("..", "testdata", "ols", "conf", "httpd_config.conf"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "nobody", root.params["user"])
+	assert.Equal(t, "RewriteCond %{HTTP_HOST} ^www\\.(.+)$ [NC]\nRewriteRule ^ - [L]", root.child("rewrite").params["rules"])
+	assert.Equal(t, "10000", root.child("tuning").params["maxConnections"])
+
+	listeners := root.children("listener")
+	assert.Len(t, listeners, 2)
+	assert.Equal(t, "SSL", listeners[1].name)
+	assert.Equal(t, "*:443", listeners[1].params["address"])
+
+	members := root.child("vhtemplate").children("member")
+	assert.Len(t, members, 2)
+	assert.Equal(t, "/home/shop", members[0].params["vhRoot"])
+	assert.Equal(t, "blog.example.com", members[1].name)
+}
+
+func TestParseXMLConfig(t *testing.T) {
+	root, err := parseConfigFile(path.Join("..", "testdata", "lsws", "conf", "httpd_config.xml"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "nobody", root.params["user"])
+	assert.Equal(t, "2000", root.child("tuning").params["maxConnections"])
+	assert.Len(t, root.children("virtualhost"), 2)
+	assert.Equal(t, "$SERVER_ROOT/DEFAULT/", root.child("virtualhost").params["vhRoot"])
+	assert.Equal(t, "lsphp", root.child("extprocessor").name)
+	assert.Equal(t, "Default", root.child("listener").name)
+	assert.Equal(t, "Example", root.child("listener").child("vhostmap").params["vhost"])
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		parse  func(io.Reader) (*configBlock, error)
+		config string
+	}{
+		{"unexpected brace", parseOLSConfig, "user nobody\n}\n"},
+		{"missing brace", parseOLSConfig, "listener Default {\n  address *:80\n"},
+		{"missing marker", parseOLSConfig, "rules <<<END_rules\nRewriteRule ^ - [L]\n"},
+		{"invalid xml", parseXMLConfig, "<httpServerConfig><user>nobody</httpServerConfig>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.parse(strings.NewReader(tt.config))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseConfigValue(t *testing.T) {
+	tests := []struct {
+		value string
+		bytes bool
+		want  float64
+	}{
+		{"10", false, 10},
+		{"2047M", true, 2047 << 20},
+		{"512K", true, 512 << 10},
+		{"1G", true, 1 << 30},
+		{"1048576", true, 1 << 20},
+	}
+
+	for _, tt := range tests {
+		v, err := parseConfigValue(tt.value, tt.bytes)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.want, v, tt.value)
+	}
+
+	_, err := parseConfigValue("2047M", false)
+	assert.Error(t, err)
+}
+
+func TestConfigCollectorOLS(t *testing.T) {
+	serverRoot, _ := filepath.Abs(path.Join("..", "testdata", "ols"))
+	c := NewConfigCollector(ConfigCollectorOpts{ServerRoot: serverRoot}, log.NewNopLogger())
+
+	expected := fmt.Sprintf(`
+# HELP litespeed_extapp_config_max_connections Maximum number of connections to the external application configured.
+# TYPE litespeed_extapp_config_max_connections gauge
+litespeed_extapp_config_max_connections{handler="blog.example.com_php",vhost="blog.example.com"} 3
+litespeed_extapp_config_max_connections{handler="example_php",vhost="Example"} 5
+litespeed_extapp_config_max_connections{handler="lsphp",vhost=""} 10
+litespeed_extapp_config_max_connections{handler="shop.example.com_php",vhost="shop.example.com"} 3
+# HELP litespeed_extapp_config_memory_hard_limit_bytes Hard limit of the memory of the external application processes configured.
+# TYPE litespeed_extapp_config_memory_hard_limit_bytes gauge
+litespeed_extapp_config_memory_hard_limit_bytes{handler="example_php",vhost="Example"} 1.073741824e+09
+litespeed_extapp_config_memory_hard_limit_bytes{handler="lsphp",vhost=""} 2.146435072e+09
+# HELP litespeed_listener_info Listener declared in the LiteSpeed configuration, always 1.
+# TYPE litespeed_listener_info gauge
+litespeed_listener_info{address="*:443",name="SSL",secure="true"} 1
+litespeed_listener_info{address="*:8088",name="Default",secure="false"} 1
+# HELP litespeed_vhost_info Virtual host declared in the LiteSpeed configuration, always 1.
+# TYPE litespeed_vhost_info gauge
+litespeed_vhost_info{docroot="%s/Example/html",user="example",vhost="Example"} 1
+litespeed_vhost_info{docroot="/home/blog.example.com/public_html",user="blog.example.com",vhost="blog.example.com"} 1
+litespeed_vhost_info{docroot="/home/shop/public_html",user="shop.example.com",vhost="shop.example.com"} 1
+`, serverRoot)
+	names := []string{
+		"litespeed_extapp_config_max_connections",
+		"litespeed_extapp_config_memory_hard_limit_bytes",
+		"litespeed_listener_info",
+		"litespeed_vhost_info",
+	}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), names...); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.scrapeFailures))
+}
+
+func TestConfigCollectorLSWS(t *testing.T) {
+	serverRoot, _ := filepath.Abs(path.Join("..", "testdata", "lsws"))
+	c := NewConfigCollector(ConfigCollectorOpts{ServerRoot: serverRoot}, log.NewNopLogger())
+
+	expected := fmt.Sprintf(`
+# HELP litespeed_extapp_config_process_soft_limit Soft limit of the number of processes of the external application user configured.
+# TYPE litespeed_extapp_config_process_soft_limit gauge
+litespeed_extapp_config_process_soft_limit{handler="lsphp",vhost=""} 400
+# HELP litespeed_listener_info Listener declared in the LiteSpeed configuration, always 1.
+# TYPE litespeed_listener_info gauge
+litespeed_listener_info{address="*:8088",name="Default",secure="false"} 1
+# HELP litespeed_vhost_info Virtual host declared in the LiteSpeed configuration, always 1.
+# TYPE litespeed_vhost_info gauge
+litespeed_vhost_info{docroot="",user="",vhost="Missing"} 1
+litespeed_vhost_info{docroot="%s/DEFAULT/html",user="",vhost="Example"} 1
+`, serverRoot)
+	names := []string{
+		"litespeed_extapp_config_process_soft_limit",
+		"litespeed_listener_info",
+		"litespeed_vhost_info",
+	}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), names...); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+	// The configuration of the Missing virtual host can't be read
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.scrapeFailures))
+}
+
+func TestConfigCollectorMissingConfig(t *testing.T) {
+	c := NewConfigCollector(ConfigCollectorOpts{ServerRoot: path.Join("..", "testdata", "non-existing-dir")}, log.NewNopLogger())
+
+	assert.Equal(t, 3, testutil.CollectAndCount(c))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.scrapeFailures))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.parseErrors))
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.lastReload))
+
+	// The configuration isn't read again until it changes
+	testutil.CollectAndCount(c)
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.scrapeFailures))
+}
+
+func TestConfigCollectorReloadsChangedConfig(t *testing.T) {
+	serverRoot, _ := ioutil.TempDir("", "TestConfigCollectorReloadsChangedConfig")
+	defer os.RemoveAll(serverRoot)
+	os.MkdirAll(filepath.Join(serverRoot, "conf", "vhosts"), 0755)
+	ioutil.WriteFile(filepath.Join(serverRoot, "conf", "httpd_config.conf"), []byte("virtualhost Example {\n  vhRoot /home/example\n  configFile conf/vhosts/example.conf\n}\n"), 0644)
+	vhconf := filepath.Join(serverRoot, "conf", "vhosts", "example.conf")
+	writeVhconf := func(docRoot string, modTime time.Time) {
+		ioutil.WriteFile(vhconf, []byte("docRoot "+docRoot+"\n"), 0644)
+		os.Chtimes(vhconf, modTime, modTime)
+	}
+	expected := func(docRoot string) string {
+		return fmt.Sprintf(`
+# HELP litespeed_vhost_info Virtual host declared in the LiteSpeed configuration, always 1.
+# TYPE litespeed_vhost_info gauge
+litespeed_vhost_info{docroot=%q,user="",vhost="Example"} 1
+`, docRoot)
+	}
+
+	c := NewConfigCollector(ConfigCollectorOpts{ServerRoot: serverRoot}, log.NewNopLogger())
+	modTime := time.Now().Add(-time.Hour)
+	writeVhconf("$VH_ROOT/html", modTime)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected("/home/example/html")), "litespeed_vhost_info"); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+	assert.InDelta(t, float64(time.Now().Unix()), testutil.ToFloat64(c.lastReload), 60)
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.parseErrors))
+
+	// Files keeping their modification time aren't read again...
+	writeVhconf("$VH_ROOT/public_html", modTime)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected("/home/example/html")), "litespeed_vhost_info"); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+
+	// ...but the ones of the virtual hosts are watched along with the main one
+	writeVhconf("$VH_ROOT/public_html", modTime.Add(time.Minute))
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected("/home/example/public_html")), "litespeed_vhost_info"); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+
+	os.Remove(vhconf)
+	testutil.CollectAndCount(c)
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.parseErrors))
+}
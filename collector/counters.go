@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/hostinger/litespeed_exporter/rtreport"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// counterSeries identifies a counter field of the summed report
+type counterSeries struct {
+	section, service, hostname, handler, field string
+}
+
+// coreCounter identifies a counter field in the report of a single core
+type coreCounter struct {
+	core string
+	counterSeries
+}
+
+// counterRetention is how long a counter is kept once it's gone from the
+// reports, so that a core coming back after a while, such as a LiteSpeed
+// hung for some minutes, doesn't count its whole value again
+const counterRetention = 24 * time.Hour
+
+// trackedValue is a value of the tracker along with when it was last seen
+type trackedValue struct {
+	value float64
+	seen  time.Time
+}
+
+// counterTracker keeps the counters of summed up and folded reports
+// monotonic. Summing the raw values makes the total drop whenever a core
+// restarts, its report goes away or a host leaves the __other__ ones, so the
+// increase of every series of every core is accumulated between scrapes
+// instead, a value lower than the previous one being taken as a reset.
+type counterTracker struct {
+	// last holds the raw values by core, totals the accumulated values of the
+	// series exported, the core being empty when the reports are summed up
+	last   map[coreCounter]trackedValue
+	totals map[coreCounter]trackedValue
+	// cores holds when every core was last seen. The counters first seen on
+	// a core already known, such as the ones forgotten while it was gone or
+	// the ones of a new host, start from their current value rather than
+	// adding it to the totals.
+	cores map[string]time.Time
+	// started is when the current scrape started
+	started time.Time
+}
+
+func newCounterTracker() *counterTracker {
+	return &counterTracker{
+		last:   make(map[coreCounter]trackedValue),
+		totals: make(map[coreCounter]trackedValue),
+		cores:  make(map[string]time.Time),
+	}
+}
+
+// next starts a new scrape, forgetting the counters not seen for counterRetention
+func (t *counterTracker) next(now time.Time) {
+	t.started = now
+	for k, v := range t.last {
+		if now.Sub(v.seen) > counterRetention {
+			delete(t.last, k)
+		}
+	}
+	for k, v := range t.totals {
+		if now.Sub(v.seen) > counterRetention {
+			delete(t.totals, k)
+		}
+	}
+}
+
+// observe accumulates the value of a counter in the report of a core under
+// the series it's exported as, and tells whether the counter was reset since
+// the previous scrape
+func (t *counterTracker) observe(raw, exported coreCounter, v float64) bool {
+	prev, seen := t.last[raw]
+	coreSeen, coreKnown := t.cores[raw.core]
+	t.last[raw] = trackedValue{value: v, seen: t.started}
+	t.cores[raw.core] = t.started
+
+	reset := seen && v < prev.value
+	var increase float64
+	switch {
+	case reset:
+		increase = v
+	case seen:
+		increase = v - prev.value
+	case !coreKnown || !coreSeen.Before(t.started):
+		increase = v
+	}
+	t.totals[exported] = trackedValue{value: t.totals[exported].value + increase, seen: t.started}
+	return reset
+}
+
+// total returns the accumulated value of an exported counter
+func (t *counterTracker) total(exported coreCounter) (float64, bool) {
+	v, ok := t.totals[exported]
+	return v.value, ok
+}
+
+// isCounter tells whether a field is exported as a counter
+func (c *LitespeedCollector) isCounter(flag string) bool {
+	metric, ok := c.lookupMetric(flag)
+	return ok && metric.Type == prometheus.CounterValue
+}
+
+// countersTracked tells whether the counters are accumulated by the tracker
+// rather than exported as read
+func (c *LitespeedCollector) countersTracked() bool {
+	return !c.options.MetricsByCore || c.options.TopHosts > 0
+}
+
+// observeReports feeds the counters of every core to the tracker, before the
+// reports get folded and summed up
+func (c *LitespeedCollector) observeReports(reports map[string]rtreport.Report) {
+	c.counters.next(time.Now())
+	for core, report := range reports {
+		c.observeCounters(core, counterSeries{section: "general"}, report.GeneralInfo.KeyValues)
+		for _, rrReport := range report.ReqRates {
+			c.observeCounters(core, counterSeries{section: "req_rate", hostname: rrReport.Hostname}, rrReport.KeyValues)
+		}
+		for _, eaReport := range report.ExtApps {
+			c.observeCounters(core, counterSeries{section: "extapp", service: eaReport.Service, hostname: eaReport.Hostname, handler: eaReport.Handler}, eaReport.KeyValues)
+		}
+	}
+}
+
+// replaceCounters replaces the counters of the reports about to be exported
+// with the accumulated totals
+func (c *LitespeedCollector) replaceCounters(reports map[string]rtreport.Report) {
+	for core, report := range reports {
+		c.replaceValues(core, counterSeries{section: "general"}, report.GeneralInfo.KeyValues)
+		for _, rrReport := range report.ReqRates {
+			c.replaceValues(core, counterSeries{section: "req_rate", hostname: rrReport.Hostname}, rrReport.KeyValues)
+		}
+		for _, eaReport := range report.ExtApps {
+			c.replaceValues(core, counterSeries{section: "extapp", service: eaReport.Service, hostname: eaReport.Hostname, handler: eaReport.Handler}, eaReport.KeyValues)
+		}
+	}
+}
+
+// exportedCounter returns the series a counter of a core is exported as
+func (c *LitespeedCollector) exportedCounter(core string, s counterSeries) coreCounter {
+	if !c.options.MetricsByCore {
+		core = ""
+	}
+	if c.options.TopHosts > 0 {
+		s.hostname, s.handler = c.foldedHost(s.section, s.hostname, s.handler)
+	}
+	return coreCounter{core: core, counterSeries: s}
+}
+
+func (c *LitespeedCollector) observeCounters(core string, s counterSeries, kv map[string]float64) {
+	for flag, v := range kv {
+		if !c.isCounter(flag) {
+			continue
+		}
+		s.field = flag
+		if c.counters.observe(coreCounter{core: core, counterSeries: s}, c.exportedCounter(core, s), v) {
+			level.Debug(c.logger).Log("msg", "Counter reset absorbed", "core", core, "section", s.section, "hostname", s.hostname, "handler", s.handler, "field", flag, "value", v)
+			c.counterResets.WithLabelValues(core).Inc()
+		}
+	}
+}
+
+func (c *LitespeedCollector) replaceValues(core string, s counterSeries, kv map[string]float64) {
+	for flag := range kv {
+		if !c.isCounter(flag) {
+			continue
+		}
+		s.field = flag
+		if v, ok := c.counters.total(coreCounter{core: core, counterSeries: s}); ok {
+			kv[flag] = v
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterTrackerAbsorbsResets(t *testing.T) {
+	tracker := newCounterTracker()
+	s := counterSeries{section: "req_rate", hostname: "test.com", field: reqRateTotReqsField}
+	exported := coreCounter{counterSeries: s}
+
+	tests := []struct {
+		core  string
+		value float64
+		reset bool
+		want  float64
+	}{
+		{"core.1", 100, false, 100},
+		{"core.2", 50, false, 150},
+		{"core.1", 120, false, 170},
+		{"core.1", 120, false, 170},
+		{"core.2", 10, true, 180},
+		{"core.1", 5, true, 185},
+		{"core.3", 30, false, 215},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.reset, tracker.observe(coreCounter{core: tc.core, counterSeries: s}, exported, tc.value))
+		total, ok := tracker.total(exported)
+		assert.True(t, ok)
+		assert.Equal(t, tc.want, total)
+	}
+
+	_, ok := tracker.total(coreCounter{counterSeries: counterSeries{section: "req_rate", hostname: "other.com", field: reqRateTotReqsField}})
+	assert.False(t, ok)
+}
+
+func TestCounterTrackerForgetsMissingCounters(t *testing.T) {
+	tracker := newCounterTracker()
+	kept := coreCounter{core: "core.1", counterSeries: counterSeries{section: "req_rate", hostname: "kept.com", field: reqRateTotReqsField}}
+	gone := coreCounter{core: "core.1", counterSeries: counterSeries{section: "req_rate", hostname: "gone.com", field: reqRateTotReqsField}}
+	now := time.Now()
+
+	tracker.next(now)
+	tracker.observe(kept, kept, 10)
+	tracker.observe(gone, gone, 10)
+
+	tracker.next(now.Add(counterRetention))
+	tracker.observe(kept, kept, 10)
+	_, ok := tracker.total(gone)
+	assert.True(t, ok, "Counters are kept for a while once gone")
+
+	tracker.next(now.Add(counterRetention + time.Minute))
+	tracker.observe(kept, kept, 10)
+	_, ok = tracker.total(gone)
+	assert.False(t, ok)
+	assert.NotContains(t, tracker.last, gone)
+	assert.Len(t, tracker.last, 1)
+	assert.Len(t, tracker.totals, 1)
+}
+
+func TestCounterTrackerKeepsTotalsOfCoresComingBack(t *testing.T) {
+	tracker := newCounterTracker()
+	s := counterSeries{section: "req_rate", field: reqRateTotReqsField}
+	core1 := coreCounter{core: "core.1", counterSeries: s}
+	core2 := coreCounter{core: "core.2", counterSeries: s}
+	exported := coreCounter{counterSeries: s}
+	now := time.Now()
+
+	tests := []struct {
+		after time.Duration
+		// values by core, the core being gone when missing
+		values map[coreCounter]float64
+		want   float64
+	}{
+		{0, map[coreCounter]float64{core1: 1e6, core2: 1000}, 1001000},
+		// core.1 hangs for longer than a few scrapes
+		{10 * time.Minute, map[coreCounter]float64{core2: 1001}, 1001001},
+		{20 * time.Minute, map[coreCounter]float64{core1: 1e6 + 1, core2: 1001}, 1001002},
+		// and then for longer than the retention, its value being taken as
+		// a new baseline when it comes back
+		{12 * time.Hour, map[coreCounter]float64{core2: 1001}, 1001002},
+		{counterRetention + 30*time.Minute, map[coreCounter]float64{core2: 1001}, 1001002},
+		{counterRetention + 40*time.Minute, map[coreCounter]float64{core1: 1e6 + 1, core2: 1001}, 1001002},
+		{counterRetention + 50*time.Minute, map[coreCounter]float64{core1: 1e6 + 2, core2: 1002}, 1001004},
+	}
+
+	for _, tc := range tests {
+		tracker.next(now.Add(tc.after))
+		for raw, v := range tc.values {
+			assert.False(t, tracker.observe(raw, exported, v))
+		}
+		total, ok := tracker.total(exported)
+		assert.True(t, ok)
+		assert.Equal(t, tc.want, total, "Unexpected total after %s", tc.after)
+	}
+}
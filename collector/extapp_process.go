@@ -0,0 +1,254 @@
+package collector
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+)
+
+// DefaultExtappProcessNames matches the names of the LSAPI processes
+// LiteSpeed spawns for external applications
+var DefaultExtappProcessNames = regexp.MustCompile(`^(lsphp|php|lswsgi)`)
+
+// ExtappProcessCollectorOpts carries the options used in ExtappProcessCollector
+type ExtappProcessCollectorOpts struct {
+	// ProcfsPath is where procfs is mounted, procfs.DefaultMountPoint when empty
+	ProcfsPath string
+	// PasswdFile resolves the UIDs of the processes to user names, DefaultPasswdFile when empty
+	PasswdFile string
+	// ProcessNames matches the names of the processes to group, DefaultExtappProcessNames when nil
+	ProcessNames *regexp.Regexp
+	// Handlers returns the external application handlers the processes are
+	// grouped by, such as LitespeedCollector.Handlers
+	Handlers func() []string
+}
+
+var (
+	extappProcesses  = prometheus.NewDesc(prometheus.BuildFQName(namespace, "extapp", "processes"), "Number of external application processes, by handler and user.", []string{"handler", "user"}, nil)
+	extappProcessRSS = prometheus.NewDesc(prometheus.BuildFQName(namespace, "extapp", "process_resident_memory_bytes"), "Resident memory size of the external application processes in bytes, by handler and user.", []string{"handler", "user"}, nil)
+	extappProcessCPU = prometheus.NewDesc(prometheus.BuildFQName(namespace, "extapp", "process_cpu_seconds_total"), "Total user and system CPU time spent by the external application processes in seconds, by handler and user.", []string{"handler", "user"}, nil)
+)
+
+// processGroup identifies the processes of a handler run by a user
+type processGroup struct {
+	handler, user string
+}
+
+// processKey tells apart a process from a later one reusing its PID
+type processKey struct {
+	pid       int
+	startTime uint64
+}
+
+type processSample struct {
+	group processGroup
+	cpu   float64
+}
+
+// cpuGroupRetention is the number of scrapes the CPU time of a group is kept
+// for once its processes are gone, so that a group whose processes are only
+// spawned on demand doesn't start over every time
+const cpuGroupRetention = 10
+
+// groupCPU is the CPU time accumulated by a group along with the scrape it
+// was last seen in
+type groupCPU struct {
+	total  float64
+	scrape uint64
+}
+
+// ExtappProcessCollector exports the resource usage of the external
+// application processes, such as lsphp, grouped by handler and user
+type ExtappProcessCollector struct {
+	mutex          sync.Mutex
+	options        ExtappProcessCollectorOpts
+	fs             procfs.FS
+	passwd         *passwdFile
+	scrapeFailures prometheus.Counter
+	// lastCPU and cpuTotals keep the CPU time of a group monotonic while its
+	// processes are recycled
+	lastCPU   map[processKey]processSample
+	cpuTotals map[processGroup]groupCPU
+	scrape    uint64
+	logger    log.Logger
+}
+
+// NewExtappProcessCollector returns constructed collector, failing when
+// procfs can't be found
+func NewExtappProcessCollector(opts ExtappProcessCollectorOpts, logger log.Logger) (*ExtappProcessCollector, error) {
+	if opts.ProcfsPath == "" {
+		opts.ProcfsPath = procfs.DefaultMountPoint
+	}
+	if opts.PasswdFile == "" {
+		opts.PasswdFile = DefaultPasswdFile
+	}
+	if opts.ProcessNames == nil {
+		opts.ProcessNames = DefaultExtappProcessNames
+	}
+	if opts.Handlers == nil {
+		opts.Handlers = func() []string { return nil }
+	}
+
+	fs, err := procfs.NewFS(opts.ProcfsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExtappProcessCollector{
+		options: opts,
+		fs:      fs,
+		passwd:  &passwdFile{path: opts.PasswdFile},
+		scrapeFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "extapp",
+			Name:      "process_scrape_failures_total",
+			Help:      "Number of errors while reading the external application processes.",
+		}),
+		lastCPU:   make(map[processKey]processSample),
+		cpuTotals: make(map[processGroup]groupCPU),
+		logger:    logger,
+	}, nil
+}
+
+// Describe describes all the metrics that can be exported by the external application process collector
+func (c *ExtappProcessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- extappProcesses
+	ch <- extappProcessRSS
+	ch <- extappProcessCPU
+	ch <- c.scrapeFailures.Desc()
+}
+
+// Collect reads the external application processes and delivers their usage as Prometheus metrics
+func (c *ExtappProcessCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.collectProcesses(ch)
+	ch <- c.scrapeFailures
+}
+
+func (c *ExtappProcessCollector) collectProcesses(ch chan<- prometheus.Metric) {
+	if err := c.passwd.refresh(); err != nil {
+		// Users are then labeled by UID
+		level.Warn(c.logger).Log("msg", "Can't read passwd file", "file", c.options.PasswdFile, "err", err)
+	}
+
+	procs, err := c.fs.AllProcs()
+	if err != nil {
+		level.Error(c.logger).Log("msg", "Can't list processes", "err", err)
+		c.scrapeFailures.Inc()
+		return
+	}
+
+	handlers := c.options.Handlers()
+	counts := make(map[processGroup]float64)
+	rss := make(map[processGroup]float64)
+	samples := make(map[processKey]processSample)
+
+	for _, proc := range procs {
+		// Processes can exit while being listed, so errors are skipped
+		stat, err := proc.Stat()
+		if err != nil || !c.options.ProcessNames.MatchString(stat.Comm) {
+			continue
+		}
+		status, err := proc.NewStatus()
+		if err != nil {
+			continue
+		}
+
+		uid := status.UIDs[0]
+		group := processGroup{
+			handler: processHandler(handlers, stat.Comm, processCommand(proc), uid),
+			user:    c.passwd.lookup(uid),
+		}
+		counts[group]++
+		rss[group] += float64(stat.ResidentMemory())
+		samples[processKey{pid: proc.PID, startTime: stat.Starttime}] = processSample{group: group, cpu: stat.CPUTime()}
+	}
+
+	c.trackCPU(samples)
+
+	for group, count := range counts {
+		ch <- prometheus.MustNewConstMetric(extappProcesses, prometheus.GaugeValue, count, group.handler, group.user)
+		ch <- prometheus.MustNewConstMetric(extappProcessRSS, prometheus.GaugeValue, rss[group], group.handler, group.user)
+		ch <- prometheus.MustNewConstMetric(extappProcessCPU, prometheus.CounterValue, c.cpuTotals[group].total, group.handler, group.user)
+	}
+}
+
+// trackCPU accumulates the CPU time spent by every process since the
+// previous scrape, so that processes exiting don't make the groups go down,
+// and forgets the groups gone for cpuGroupRetention scrapes
+func (c *ExtappProcessCollector) trackCPU(samples map[processKey]processSample) {
+	c.scrape++
+	for key, sample := range samples {
+		increase := sample.cpu
+		if prev, ok := c.lastCPU[key]; ok && sample.cpu >= prev.cpu {
+			increase = sample.cpu - prev.cpu
+		}
+		c.cpuTotals[sample.group] = groupCPU{total: c.cpuTotals[sample.group].total + increase, scrape: c.scrape}
+	}
+	c.lastCPU = samples
+
+	for group, cpu := range c.cpuTotals {
+		if c.scrape-cpu.scrape > cpuGroupRetention {
+			delete(c.cpuTotals, group)
+		}
+	}
+}
+
+// processCommand returns the executable of a process, falling back to its
+// first argument when the executable can't be read without being root
+func processCommand(proc procfs.Proc) string {
+	if exe, err := proc.Executable(); err == nil && exe != "" {
+		return exe
+	}
+	if cmdline, err := proc.CmdLine(); err == nil && len(cmdline) > 0 {
+		return cmdline[0]
+	}
+	return ""
+}
+
+// processHandler returns the handler a process belongs to, its name when no
+// handler matches:
+//   - handlers suffixed by a UID, such as lsphp.10000, match the processes of
+//     that user whose command contains the rest of the name
+//   - other handlers, such as lsphp74, match the processes whose command
+//     contains their name, the longest one winning
+func processHandler(handlers []string, name, command, uid string) string {
+	best := ""
+	for _, handler := range handlers {
+		if base, handlerUID, ok := splitHandlerUID(handler); ok {
+			if handlerUID == uid && strings.Contains(command, base) {
+				return handler
+			}
+			continue
+		}
+		if strings.Contains(command, handler) && len(handler) > len(best) {
+			best = handler
+		}
+	}
+
+	if best == "" {
+		return name
+	}
+	return best
+}
+
+// splitHandlerUID splits the handlers LiteSpeed spawns for every user, such
+// as lsphp.10000, into their base name and UID
+func splitHandlerUID(handler string) (string, string, bool) {
+	i := strings.LastIndex(handler, ".")
+	if i <= 0 || i == len(handler)-1 {
+		return "", "", false
+	}
+	if _, err := strconv.ParseUint(handler[i+1:], 10, 32); err != nil {
+		return "", "", false
+	}
+	return handler[:i], handler[i+1:], true
+}
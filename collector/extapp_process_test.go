@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtappProcessCollectorGroupsByHandlerAndUser(t *testing.T) {
+	c, err := NewExtappProcessCollector(
+		ExtappProcessCollectorOpts{
+			ProcfsPath: path.Join("..", "testdata", "proc"),
+			PasswdFile: path.Join("..", "testdata", "passwd"),
+			Handlers: func() []string {
+				return []string{"ABCDEFGH_php72:", "lsphp.10000", "lsphp72"}
+			},
+		},
+		log.NewNopLogger(),
+	)
+	assert.NoError(t, err)
+
+	// Resident memory is reported in pages
+	pageSize := os.Getpagesize()
+	expected := fmt.Sprintf(`
+# HELP litespeed_extapp_process_cpu_seconds_total Total user and system CPU time spent by the external application processes in seconds, by handler and user.
+# TYPE litespeed_extapp_process_cpu_seconds_total counter
+litespeed_extapp_process_cpu_seconds_total{handler="lsphp",user="10002"} 1
+litespeed_extapp_process_cpu_seconds_total{handler="lsphp",user="bob"} 2
+litespeed_extapp_process_cpu_seconds_total{handler="lsphp.10000",user="alice"} 10
+litespeed_extapp_process_cpu_seconds_total{handler="lsphp72",user="nobody"} 2
+# HELP litespeed_extapp_process_resident_memory_bytes Resident memory size of the external application processes in bytes, by handler and user.
+# TYPE litespeed_extapp_process_resident_memory_bytes gauge
+litespeed_extapp_process_resident_memory_bytes{handler="lsphp",user="10002"} %d
+litespeed_extapp_process_resident_memory_bytes{handler="lsphp",user="bob"} %d
+litespeed_extapp_process_resident_memory_bytes{handler="lsphp.10000",user="alice"} %d
+litespeed_extapp_process_resident_memory_bytes{handler="lsphp72",user="nobody"} %d
+# HELP litespeed_extapp_process_scrape_failures_total Number of errors while reading the external application processes.
+# TYPE litespeed_extapp_process_scrape_failures_total counter
+litespeed_extapp_process_scrape_failures_total 0
+# HELP litespeed_extapp_processes Number of external application processes, by handler and user.
+# TYPE litespeed_extapp_processes gauge
+litespeed_extapp_processes{handler="lsphp",user="10002"} 1
+litespeed_extapp_processes{handler="lsphp",user="bob"} 1
+litespeed_extapp_processes{handler="lsphp.10000",user="alice"} 2
+litespeed_extapp_processes{handler="lsphp72",user="nobody"} 1
+`, 1024*pageSize, 1024*pageSize, 12288*pageSize, 2048*pageSize)
+
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+}
+
+func TestExtappProcessCollectorKeepsCPUMonotonic(t *testing.T) {
+	c, err := NewExtappProcessCollector(ExtappProcessCollectorOpts{ProcfsPath: path.Join("..", "testdata", "proc")}, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	group := processGroup{handler: "lsphp74", user: "alice"}
+	tests := []struct {
+		samples map[processKey]processSample
+		want    float64
+	}{
+		{map[processKey]processSample{{1, 100}: {group, 10}, {2, 100}: {group, 5}}, 15},
+		{map[processKey]processSample{{1, 100}: {group, 12}, {2, 100}: {group, 6}}, 18},
+		// The second process exited and its PID got reused
+		{map[processKey]processSample{{1, 100}: {group, 13}, {2, 200}: {group, 1}}, 20},
+		{map[processKey]processSample{{2, 200}: {group, 3}}, 22},
+	}
+
+	for _, tc := range tests {
+		c.trackCPU(tc.samples)
+		assert.Equal(t, tc.want, c.cpuTotals[group].total)
+	}
+}
+
+func TestExtappProcessCollectorForgetsGoneGroups(t *testing.T) {
+	c, err := NewExtappProcessCollector(ExtappProcessCollectorOpts{ProcfsPath: path.Join("..", "testdata", "proc")}, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	kept := processGroup{handler: "lsphp74", user: "alice"}
+	gone := processGroup{handler: "lsphp74", user: "bob"}
+
+	c.trackCPU(map[processKey]processSample{{1, 100}: {kept, 10}, {2, 100}: {gone, 5}})
+	for i := 0; i < cpuGroupRetention; i++ {
+		c.trackCPU(map[processKey]processSample{{1, 100}: {kept, 10}})
+	}
+	assert.Contains(t, c.cpuTotals, gone, "Groups are kept for a while once gone")
+
+	c.trackCPU(map[processKey]processSample{{1, 100}: {kept, 10}})
+	assert.NotContains(t, c.cpuTotals, gone)
+	assert.Equal(t, 10.0, c.cpuTotals[kept].total)
+}
+
+func TestProcessHandlerReturnsExpected(t *testing.T) {
+	handlers := []string{"lsphp", "lsphp.10000", "lsphp74", "lsphp74.10001"}
+
+	tests := []struct {
+		command, uid string
+		e            string
+	}{
+		{"/usr/local/lsws/lsphp74/bin/lsphp", "10000", "lsphp.10000"},
+		{"/usr/local/lsws/lsphp74/bin/lsphp", "10001", "lsphp74.10001"},
+		{"/usr/local/lsws/lsphp74/bin/lsphp", "10002", "lsphp74"},
+		{"/usr/local/lsws/lsphp80/bin/lsphp", "10002", "lsphp"},
+		{"/usr/bin/php-cgi", "10002", "php-cgi"},
+		{"", "10002", "php-cgi"},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.e, processHandler(handlers, "php-cgi", tc.command, tc.uid), tc.command)
+	}
+}
+
+func TestSplitHandlerUIDReturnsExpected(t *testing.T) {
+	tests := []struct {
+		handler   string
+		base, uid string
+		ok        bool
+	}{
+		{"lsphp.10000", "lsphp", "10000", true},
+		{"lsphp7.4.10000", "lsphp7.4", "10000", true},
+		{"lsphp72", "", "", false},
+		{"lsphp.", "", "", false},
+		{".10000", "", "", false},
+		{"lsphp.abc", "", "", false},
+	}
+
+	for _, tc := range tests {
+		base, uid, ok := splitHandlerUID(tc.handler)
+		assert.Equal(t, tc.base, base, tc.handler)
+		assert.Equal(t, tc.uid, uid, tc.handler)
+		assert.Equal(t, tc.ok, ok, tc.handler)
+	}
+}
@@ -1,36 +1,37 @@
 package collector
 
+import "github.com/hostinger/litespeed_exporter/rtreport"
+
 const (
-	versionField                       = "VERSION"
-	uptimeField                        = "UPTIME"
-	bpsInField                         = "BPS_IN"
-	bpsOutField                        = "BPS_OUT"
-	sslBpsInField                      = "SSL_BPS_IN"
-	sslBpsOutField                     = "SSL_BPS_OUT"
-	maxconnField                       = "MAXCONN"
-	maxsslConnField                    = "MAXSSL_CONN"
-	plainconnField                     = "PLAINCONN"
-	availconnField                     = "AVAILCONN"
-	idleconnField                      = "IDLECONN"
-	sslconnField                       = "SSLCONN"
-	availsslField                      = "AVAILSSL"
-	reqRateField                       = "REQ_RATE"
-	reqRateReqProcessingField          = "REQ_RATE_REQ_PROCESSING"
-	reqRateReqPerSecField              = "REQ_RATE_REQ_PER_SEC"
-	reqRateTotReqsField                = "REQ_RATE_TOT_REQS"
-	reqRatePubCacheHitsPerSecField     = "REQ_RATE_PUB_CACHE_HITS_PER_SEC"
-	reqRateTotalPubCacheHitsField      = "REQ_RATE_TOTAL_PUB_CACHE_HITS"
-	reqRatePrivateCacheHitsPerSecField = "REQ_RATE_PRIVATE_CACHE_HITS_PER_SEC"
-	reqRateTotalPrivateCacheHitsField  = "REQ_RATE_TOTAL_PRIVATE_CACHE_HITS"
-	reqRateStaticHitsPerSecField       = "REQ_RATE_STATIC_HITS_PER_SEC"
-	reqRateTotalStaticHitsField        = "REQ_RATE_TOTAL_STATIC_HITS"
-	extappField                        = "EXTAPP"
-	extappCmaxconnField                = "EXTAPP_CMAXCONN"
-	extappEmaxconnField                = "EXTAPP_EMAXCONN"
-	extappPoolSizeField                = "EXTAPP_POOL_SIZE"
-	extappInuseConnField               = "EXTAPP_INUSE_CONN"
-	extappIdleConnField                = "EXTAPP_IDLE_CONN"
-	extappWaitqueDepthField            = "EXTAPP_WAITQUE_DEPTH"
-	extappReqPerSecField               = "EXTAPP_REQ_PER_SEC"
-	extappTotReqsField                 = "EXTAPP_TOT_REQS"
+	bpsInField                         = rtreport.BpsInField
+	bpsOutField                        = rtreport.BpsOutField
+	sslBpsInField                      = rtreport.SslBpsInField
+	sslBpsOutField                     = rtreport.SslBpsOutField
+	maxconnField                       = rtreport.MaxconnField
+	maxsslConnField                    = rtreport.MaxsslConnField
+	plainconnField                     = rtreport.PlainconnField
+	availconnField                     = rtreport.AvailconnField
+	idleconnField                      = rtreport.IdleconnField
+	sslconnField                       = rtreport.SslconnField
+	availsslField                      = rtreport.AvailsslField
+	reqRateField                       = rtreport.ReqRateField
+	reqRateReqProcessingField          = rtreport.ReqRateReqProcessingField
+	reqRateReqPerSecField              = rtreport.ReqRateReqPerSecField
+	reqRateTotReqsField                = rtreport.ReqRateTotReqsField
+	reqRatePubCacheHitsPerSecField     = rtreport.ReqRatePubCacheHitsPerSecField
+	reqRateTotalPubCacheHitsField      = rtreport.ReqRateTotalPubCacheHitsField
+	reqRatePrivateCacheHitsPerSecField = rtreport.ReqRatePrivateCacheHitsPerSecField
+	reqRateTotalPrivateCacheHitsField  = rtreport.ReqRateTotalPrivateCacheHitsField
+	reqRateStaticHitsPerSecField       = rtreport.ReqRateStaticHitsPerSecField
+	reqRateTotalStaticHitsField        = rtreport.ReqRateTotalStaticHitsField
+	extappField                        = rtreport.ExtappField
+	extappCmaxconnField                = rtreport.ExtappCmaxconnField
+	extappEmaxconnField                = rtreport.ExtappEmaxconnField
+	extappPoolSizeField                = rtreport.ExtappPoolSizeField
+	extappInuseConnField               = rtreport.ExtappInuseConnField
+	extappIdleConnField                = rtreport.ExtappIdleConnField
+	extappWaitqueDepthField            = rtreport.ExtappWaitqueDepthField
+	extappReqPerSecField               = rtreport.ExtappReqPerSecField
+	extappTotReqsField                 = rtreport.ExtappTotReqsField
+	blockedIPField                     = rtreport.BlockedIPField
 )
@@ -0,0 +1,33 @@
+package collector
+
+import "regexp"
+
+// NameFilter keeps the names matching Include, or all of them when nil, that
+// don't match Exclude
+type NameFilter struct {
+	Include *regexp.Regexp
+	Exclude *regexp.Regexp
+}
+
+// Allows tells whether name passes the filter
+func (f NameFilter) Allows(name string) bool {
+	if f.Include != nil && !f.Include.MatchString(name) {
+		return false
+	}
+	return f.Exclude == nil || !f.Exclude.MatchString(name)
+}
+
+// skipReqRate tells the parser to drop the REQ_RATE lines of the filtered out hosts
+func (c *LitespeedCollector) skipReqRate(hostname string) bool {
+	return !c.options.ReqRateHosts.Allows(hostname)
+}
+
+// skipExtApp tells the parser to drop the EXTAPP lines of the filtered out
+// services, hosts and handlers. The lines without hostname, which belong to
+// the handlers shared by all hosts, are only filtered by service and handler.
+func (c *LitespeedCollector) skipExtApp(service, hostname, handler string) bool {
+	if !c.options.ExtappServices.Allows(service) || !c.options.ExtappHandlers.Allows(handler) {
+		return true
+	}
+	return hostname != "" && !c.options.ExtappHosts.Allows(hostname)
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameFilterAllows(t *testing.T) {
+	tests := []struct {
+		filter NameFilter
+		name   string
+		want   bool
+	}{
+		{NameFilter{}, "example.com", true},
+		{NameFilter{Include: regexp.MustCompile(`^lsphp`)}, "lsphp74", true},
+		{NameFilter{Include: regexp.MustCompile(`^lsphp`)}, "lswsgi", false},
+		{NameFilter{Exclude: regexp.MustCompile(`^_AdminVHost$`)}, "_AdminVHost", false},
+		{NameFilter{Exclude: regexp.MustCompile(`^_AdminVHost$`)}, "example.com", true},
+		// Exclusions win over inclusions
+		{NameFilter{Include: regexp.MustCompile(`\.com$`), Exclude: regexp.MustCompile(`\.preview\.`)}, "site.preview.example.com", false},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, tc.filter.Allows(tc.name), "Unexpected result for %q", tc.name)
+	}
+}
+
+func TestFiltersHostsAndHandlers(t *testing.T) {
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     path.Join("..", "testdata", ".rtreport"),
+			ReqRatesByHost:  true,
+			MetricsByCore:   false,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			ReqRateHosts:    NameFilter{Exclude: regexp.MustCompile(`test`)},
+			ExtappHosts:     NameFilter{Include: regexp.MustCompile(`^www\.`)},
+			ExtappHandlers:  NameFilter{Include: regexp.MustCompile(`^lsphp`)},
+		},
+		log.NewNopLogger(),
+	)
+
+	expected := `
+# HELP litespeed_extapp_requests_total Total number of requests handled by the external application.
+# TYPE litespeed_extapp_requests_total counter
+litespeed_extapp_requests_total{core="",handler="lsphp72",hostname="",service="LSAPI"} 98765
+# HELP litespeed_requests_total Total number of requests served.
+# TYPE litespeed_requests_total counter
+litespeed_requests_total{core="",hostname=""} 2
+litespeed_requests_total{core="",hostname="localhost"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "litespeed_requests_total", "litespeed_extapp_requests_total"); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+}
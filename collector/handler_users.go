@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"github.com/go-kit/kit/log/level"
+	"github.com/hostinger/litespeed_exporter/rtreport"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// refreshPasswd reads the passwd file again when it changed, users being
+// labeled by UID when it can't be read
+func (c *LitespeedCollector) refreshPasswd() {
+	if err := c.passwd.refresh(); err != nil {
+		level.Warn(c.logger).Log("msg", "Can't read passwd file", "file", c.passwd.path, "err", err)
+	}
+}
+
+// handlerUser returns the name of the user a handler is spawned for, such as
+// the user with UID 10000 for lsphp.10000, or an empty string for the
+// handlers shared by all users
+func (c *LitespeedCollector) handlerUser(handler string) string {
+	_, uid, ok := splitHandlerUID(handler)
+	if !ok {
+		return ""
+	}
+	return c.passwd.lookup(uid)
+}
+
+// lookupUserMetric returns how an EXTAPP field is exported with the user label
+func (c *LitespeedCollector) lookupUserMetric(flag string) (metricInfo, bool) {
+	if metric, ok := c.userMetrics[flag]; ok {
+		return metric, true
+	}
+
+	metric, ok := c.lookupMetric(flag)
+	if !ok {
+		return metricInfo{}, false
+	}
+	metric = metric.withVariableLabels("user")
+	c.userMetrics[flag] = metric
+	return metric, true
+}
+
+// newUserMetrics describes the EXTAPP fields with the user label
+func newUserMetrics() metrics {
+	m := metrics{}
+	for flag, metric := range LitespeedMetrics {
+		if fieldLabelSet(flag) == extappLabelSet {
+			m[flag] = metric.withVariableLabels("user")
+		}
+	}
+	return m
+}
+
+// userExtappStats sums up the EXTAPP fields of the handlers of a user
+type userExtappStats struct {
+	inuseConn, waitqueDepth, totReqs          float64
+	hasInuseConn, hasWaitqueDepth, hasTotReqs bool
+}
+
+// collectUserMetrics exports the connections, queued requests and total
+// requests of the external applications of every user
+func (c *LitespeedCollector) collectUserMetrics(core string, reports []rtreport.ExternalApp, ch chan<- prometheus.Metric) {
+	stats := make(map[string]*userExtappStats)
+	for _, eaReport := range reports {
+		user := c.handlerUser(eaReport.Handler)
+		if user == "" {
+			continue
+		}
+
+		s, ok := stats[user]
+		if !ok {
+			s = &userExtappStats{}
+			stats[user] = s
+		}
+		if v, ok := eaReport.KeyValues[extappInuseConnField]; ok {
+			s.inuseConn += v
+			s.hasInuseConn = true
+		}
+		if v, ok := eaReport.KeyValues[extappWaitqueDepthField]; ok {
+			s.waitqueDepth += v
+			s.hasWaitqueDepth = true
+		}
+		if v, ok := eaReport.KeyValues[extappTotReqsField]; ok {
+			s.totReqs += v
+			s.hasTotReqs = true
+		}
+	}
+
+	for user, s := range stats {
+		if s.hasInuseConn {
+			ch <- prometheus.MustNewConstMetric(litespeedUserExtappConnections, prometheus.GaugeValue, s.inuseConn, core, user, "in_use")
+		}
+		if s.hasWaitqueDepth {
+			ch <- prometheus.MustNewConstMetric(litespeedUserExtappWaitQueue, prometheus.GaugeValue, s.waitqueDepth, core, user)
+		}
+		if s.hasTotReqs {
+			ch <- prometheus.MustNewConstMetric(litespeedUserExtappRequests, prometheus.CounterValue, s.totReqs, core, user)
+		}
+	}
+}
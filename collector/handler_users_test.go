@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectAggregatesExtappsByUser(t *testing.T) {
+	f, _ := ioutil.TempFile("", "TestCollectAggregatesExtappsByUser")
+	defer os.Remove(f.Name())
+	ioutil.WriteFile(f.Name(), []byte("VERSION: LiteSpeed Web Server/Enterprise/6.0.5\nBPS_IN: 1, BPS_OUT: 2\n"+
+		"EXTAPP [LSAPI] [alice.com] [lsphp.10000]: INUSE_CONN: 2, WAITQUE_DEPTH: 1, TOT_REQS: 100\n"+
+		"EXTAPP [LSAPI] [alice.net] [lsphp74.10000]: INUSE_CONN: 3, WAITQUE_DEPTH: 0, TOT_REQS: 50\n"+
+		"EXTAPP [LSAPI] [] [lsphp.10002]: INUSE_CONN: 1, WAITQUE_DEPTH: 4, TOT_REQS: 7\n"+
+		"EXTAPP [LSAPI] [] [lsphp72]: INUSE_CONN: 9, WAITQUE_DEPTH: 9, TOT_REQS: 9\n"+
+		"EOF\n"), 0644)
+
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     f.Name(),
+			ReqRatesByHost:  false,
+			MetricsByCore:   false,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			HandlerUsers:    true,
+			PasswdFile:      path.Join("..", "testdata", "passwd"),
+		},
+		log.NewNopLogger(),
+	)
+
+	expected := `
+# HELP litespeed_user_extapp_connections Number of connections to the external applications of the user, by state.
+# TYPE litespeed_user_extapp_connections gauge
+litespeed_user_extapp_connections{core="",state="in_use",user="10002"} 1
+litespeed_user_extapp_connections{core="",state="in_use",user="alice"} 5
+# HELP litespeed_user_extapp_requests_total Total number of requests handled by the external applications of the user.
+# TYPE litespeed_user_extapp_requests_total counter
+litespeed_user_extapp_requests_total{core="",user="10002"} 7
+litespeed_user_extapp_requests_total{core="",user="alice"} 150
+# HELP litespeed_user_extapp_wait_queue_depth Number of requests waiting for a connection to the external applications of the user.
+# TYPE litespeed_user_extapp_wait_queue_depth gauge
+litespeed_user_extapp_wait_queue_depth{core="",user="10002"} 4
+litespeed_user_extapp_wait_queue_depth{core="",user="alice"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "litespeed_user_extapp_connections", "litespeed_user_extapp_requests_total", "litespeed_user_extapp_wait_queue_depth"); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+}
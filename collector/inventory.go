@@ -0,0 +1,227 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/hostinger/litespeed_exporter/rtreport"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	vhostInventoryInfoName = prometheus.BuildFQName(namespace, "", "vhost_inventory_info")
+	vhostInventoryInfoHelp = "Labels of the virtual host from the inventory file, always 1."
+)
+
+// inventoryEntry maps a hostname, or a hostname glob such as *.example.com, to labels
+type inventoryEntry struct {
+	Hostname string            `yaml:"hostname" json:"hostname"`
+	Labels   map[string]string `yaml:"labels" json:"labels"`
+}
+
+// inventoryDocument is the layout of the JSON and YAML inventory files
+type inventoryDocument struct {
+	Hosts []inventoryEntry `yaml:"hosts" json:"hosts"`
+}
+
+type inventoryGlob struct {
+	pattern string
+	values  []string
+}
+
+// inventory holds the labels of every host, the hosts missing a label
+// getting it empty
+type inventory struct {
+	labelNames []string
+	hosts      map[string][]string
+	globs      []inventoryGlob
+}
+
+// parseInventory parses an inventory file, its format being guessed from
+// the extension of fileName:
+//   - .csv files have a header naming the labels, hostname being the first column
+//   - .json and .yaml files list hosts with their hostname and labels
+func parseInventory(fileName string, data []byte) (*inventory, error) {
+	var entries []inventoryEntry
+	var err error
+	switch ext := strings.ToLower(filepath.Ext(fileName)); ext {
+	case ".csv":
+		entries, err = parseCSVInventory(data)
+	case ".json":
+		var doc inventoryDocument
+		err = json.Unmarshal(data, &doc)
+		entries = doc.Hosts
+	case ".yaml", ".yml":
+		var doc inventoryDocument
+		err = yaml.UnmarshalStrict(data, &doc)
+		entries = doc.Hosts
+	default:
+		return nil, fmt.Errorf("unknown inventory format %q, expected .csv, .json or .yaml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't parse inventory file %s: %s", fileName, err)
+	}
+
+	names := make(map[string]bool)
+	for i, entry := range entries {
+		if entry.Hostname == "" {
+			return nil, fmt.Errorf("invalid inventory entry #%d in %s: missing hostname", i+1, fileName)
+		}
+		if _, err := path.Match(entry.Hostname, ""); err != nil {
+			return nil, fmt.Errorf("invalid inventory entry #%d in %s: bad hostname pattern %q", i+1, fileName, entry.Hostname)
+		}
+		for name := range entry.Labels {
+			if !model.LabelName(name).IsValid() || strings.HasPrefix(name, "__") || name == "hostname" {
+				return nil, fmt.Errorf("invalid inventory entry #%d in %s: bad label name %q", i+1, fileName, name)
+			}
+			names[name] = true
+		}
+	}
+
+	inv := &inventory{hosts: make(map[string][]string)}
+	for name := range names {
+		inv.labelNames = append(inv.labelNames, name)
+	}
+	sort.Strings(inv.labelNames)
+
+	for _, entry := range entries {
+		values := make([]string, len(inv.labelNames))
+		for i, name := range inv.labelNames {
+			values[i] = entry.Labels[name]
+		}
+
+		if strings.ContainsAny(entry.Hostname, `*?[\`) {
+			inv.globs = append(inv.globs, inventoryGlob{pattern: entry.Hostname, values: values})
+		} else if _, ok := inv.hosts[entry.Hostname]; !ok {
+			inv.hosts[entry.Hostname] = values
+		}
+	}
+	return inv, nil
+}
+
+func parseCSVInventory(data []byte) ([]inventoryEntry, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	if header[0] != "hostname" {
+		return nil, fmt.Errorf("first column must be hostname, not %q", header[0])
+	}
+
+	entries := make([]inventoryEntry, 0, len(records)-1)
+	for _, record := range records[1:] {
+		entry := inventoryEntry{Hostname: record[0], Labels: make(map[string]string, len(header)-1)}
+		for i, name := range header[1:] {
+			entry.Labels[name] = record[i+1]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// lookup returns the label values of a host, exact hostnames winning over
+// globs and globs being tried in file order
+func (inv *inventory) lookup(hostname string) ([]string, bool) {
+	if values, ok := inv.hosts[hostname]; ok {
+		return values, true
+	}
+	for _, glob := range inv.globs {
+		if ok, _ := path.Match(glob.pattern, hostname); ok {
+			return glob.values, true
+		}
+	}
+	return nil, false
+}
+
+// inventoryFile reads the inventory again whenever it changes, the previous
+// one being kept when it can't be parsed
+type inventoryFile struct {
+	path      string
+	modTime   time.Time
+	inventory *inventory
+	desc      *prometheus.Desc
+}
+
+func newInventoryFile(path string) *inventoryFile {
+	return &inventoryFile{
+		path:      path,
+		inventory: &inventory{hosts: make(map[string][]string)},
+		desc:      prometheus.NewDesc(vhostInventoryInfoName, vhostInventoryInfoHelp, []string{"hostname"}, nil),
+	}
+}
+
+// refresh reads the inventory again when it was modified since the last read
+func (f *inventoryFile) refresh() error {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	if fi.ModTime().Equal(f.modTime) {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	inv, err := parseInventory(f.path, data)
+	if err != nil {
+		return err
+	}
+
+	f.inventory, f.modTime = inv, fi.ModTime()
+	f.desc = prometheus.NewDesc(vhostInventoryInfoName, vhostInventoryInfoHelp, append([]string{"hostname"}, inv.labelNames...), nil)
+	return nil
+}
+
+// refreshInventory reads the inventory file again when it changed
+func (c *LitespeedCollector) refreshInventory() {
+	if err := c.inventory.refresh(); err != nil {
+		level.Warn(c.logger).Log("msg", "Can't read inventory file", "file", c.inventory.path, "err", err)
+	}
+}
+
+// collectVhostInventoryInfo exports the inventory labels of every host found in the
+// REQ_RATE and EXTAPP lines, for the host metrics to be joined with
+func (c *LitespeedCollector) collectVhostInventoryInfo(reports map[string]rtreport.Report, ch chan<- prometheus.Metric) {
+	seen := map[string]bool{"": true, otherHosts: true}
+	export := func(hostname string) {
+		if seen[hostname] {
+			return
+		}
+		seen[hostname] = true
+		if values, ok := c.inventory.inventory.lookup(hostname); ok {
+			ch <- prometheus.MustNewConstMetric(c.inventory.desc, prometheus.GaugeValue, 1, append([]string{hostname}, values...)...)
+		}
+	}
+
+	for _, report := range reports {
+		for _, rrReport := range report.ReqRates {
+			export(rrReport.Hostname)
+		}
+		for _, eaReport := range report.ExtApps {
+			export(eaReport.Hostname)
+		}
+	}
+}
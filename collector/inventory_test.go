@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInventory(t *testing.T) {
+	for _, fileName := range []string{"inventory.csv", "inventory.json", "inventory.yaml"} {
+		t.Run(fileName, func(t *testing.T) {
+			data, err := ioutil.ReadFile(path.Join("..", "testdata", fileName))
+			assert.Nil(t, err)
+
+			inv, err := parseInventory(fileName, data)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"customer", "plan"}, inv.labelNames)
+
+			values, ok := inv.lookup("test.com")
+			assert.True(t, ok)
+			assert.Equal(t, []string{"acme", "business"}, values)
+			values, ok = inv.lookup("www.test2.com")
+			assert.True(t, ok)
+			assert.Equal(t, []string{"globex", "starter"}, values)
+			values, ok = inv.lookup("localhost")
+			assert.True(t, ok)
+			assert.Equal(t, []string{"", "shared"}, values)
+		})
+	}
+}
+
+func TestParseInventoryErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		data     string
+		err      string
+	}{
+		{"unknown format", "inventory.txt", "", `unknown inventory format ".txt"`},
+		{"missing hostname column", "inventory.csv", "host,plan\na.com,pro\n", "first column must be hostname"},
+		{"bad label name", "inventory.csv", "hostname,plan-name\na.com,pro\n", `bad label name "plan-name"`},
+		{"reserved label name", "inventory.yaml", "hosts:\n- hostname: a.com\n  labels: {hostname: b.com}\n", `bad label name "hostname"`},
+		{"missing hostname", "inventory.json", `{"hosts": [{"labels": {"plan": "pro"}}]}`, "missing hostname"},
+		{"bad pattern", "inventory.yaml", "hosts:\n- hostname: '[a.com'\n", "bad hostname pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseInventory(tt.fileName, []byte(tt.data))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
+
+func TestInventoryFileRefresh(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "TestInventoryFileRefresh")
+	defer os.RemoveAll(dir)
+	fileName := path.Join(dir, "inventory.csv")
+
+	ioutil.WriteFile(fileName, []byte("hostname,plan\na.com,pro\n"), 0644)
+	f := newInventoryFile(fileName)
+	assert.Nil(t, f.refresh())
+	values, _ := f.inventory.lookup("a.com")
+	assert.Equal(t, []string{"pro"}, values)
+
+	// The previous inventory is kept when the new one is invalid
+	ioutil.WriteFile(fileName, []byte("host,plan\na.com,business\n"), 0644)
+	os.Chtimes(fileName, time.Now(), time.Now().Add(time.Second))
+	assert.Error(t, f.refresh())
+	values, _ = f.inventory.lookup("a.com")
+	assert.Equal(t, []string{"pro"}, values)
+
+	ioutil.WriteFile(fileName, []byte("hostname,plan,customer\na.com,business,acme\n"), 0644)
+	os.Chtimes(fileName, time.Now(), time.Now().Add(2*time.Second))
+	assert.Nil(t, f.refresh())
+	values, _ = f.inventory.lookup("a.com")
+	assert.Equal(t, []string{"acme", "business"}, values)
+}
+
+func TestCollectVhostInfo(t *testing.T) {
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     path.Join("..", "testdata", ".rtreport"),
+			ReqRatesByHost:  true,
+			MetricsByCore:   false,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			InventoryFile:   path.Join("..", "testdata", "inventory.yaml"),
+		},
+		log.NewNopLogger(),
+	)
+
+	expected := `
+# HELP litespeed_vhost_inventory_info Labels of the virtual host from the inventory file, always 1.
+# TYPE litespeed_vhost_inventory_info gauge
+litespeed_vhost_inventory_info{customer="",hostname="localhost",plan="shared"} 1
+litespeed_vhost_inventory_info{customer="acme",hostname="test.com",plan="business"} 1
+litespeed_vhost_inventory_info{customer="globex",hostname="www.test2.com",plan="starter"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "litespeed_vhost_inventory_info"); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+}
@@ -1,44 +1,182 @@
 package collector
 
 import (
-	"bufio"
 	"bytes"
-	"fmt"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
-	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/hostinger/litespeed_exporter/rtreport"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // LitespeedCollectorOpts carries the options used in LitespeedCollector
 type LitespeedCollectorOpts struct {
-	FilePattern     string
-	ReqRatesByHost  bool
-	MetricsByCore   bool
-	ExcludeExtapp   bool
-	ExcludedMetrics map[string]bool
+	FilePattern        string
+	ReqRatesByHost     bool
+	MetricsByCore      bool
+	ExcludeExtapp      bool
+	ExcludedMetrics    map[string]bool
+	BlockedIPsTopN     int
+	BlockedIPsByPrefix bool
+	// ExportUnknownMetrics exports fields missing from LitespeedMetrics as litespeed_unknown_* gauges
+	ExportUnknownMetrics bool
+	// IncompleteRetries is the number of times a partially written report is read again before it's skipped
+	IncompleteRetries      int
+	IncompleteRetryBackoff time.Duration
+	// ParseMode is either ParseModeStrict or ParseModeLenient
+	ParseMode string
+	// PIDFile is the file lshttpd writes its PID to, DefaultPIDFile when empty
+	PIDFile string
+	// Source is either SourceFile, the default, or SourceWebAdmin
+	Source           string
+	WebAdminURL      string
+	WebAdminUsername string
+	WebAdminPassword string
+	// WebAdminInsecure skips the verification of the WebAdmin TLS certificate
+	WebAdminInsecure bool
+	// LegacyMetricNames also exports the fields under the names used by previous releases
+	LegacyMetricNames bool
+	// HandlerUsers labels the EXTAPP metrics of the handlers spawned for
+	// every user, such as lsphp.10000, with the name of the user
+	HandlerUsers bool
+	// PasswdFile resolves the UIDs of the handlers to user names, DefaultPasswdFile when empty
+	PasswdFile string
+	// StaleAfter is the age above which a report is considered stale and
+	// left out, reports are never stale when 0
+	StaleAfter time.Duration
+	// SampleInterval is the interval at which RunSampler parses the reports,
+	// the sampler being disabled when 0
+	SampleInterval time.Duration
+	// SampledMetrics are the gauge fields whose lowest, highest, average and
+	// last sampled values are exported
+	SampledMetrics map[string]bool
+	// ExportRatios exports the utilisation and cache hit ratios derived from the report fields
+	ExportRatios bool
+	// TopHosts is the number of hosts exported with their own REQ_RATE and
+	// EXTAPP series, the others being summed up under the __other__ host.
+	// Every host is exported when 0.
+	TopHosts int
+	// TopHostsBy is the field hosts are ranked by, DefaultTopHostsBy when empty
+	TopHostsBy string
+	// TopHostsWindow is the window over which the rankings are smoothed
+	TopHostsWindow time.Duration
+	// ReqRateHosts filters the REQ_RATE lines of virtual hosts by hostname,
+	// the server wide line being always kept
+	ReqRateHosts NameFilter
+	// ExtappServices, ExtappHosts and ExtappHandlers filter the EXTAPP lines
+	// by service, hostname and handler
+	ExtappServices NameFilter
+	ExtappHosts    NameFilter
+	ExtappHandlers NameFilter
+	// InventoryFile is a CSV, JSON or YAML file mapping hostnames to the
+	// labels exported by litespeed_vhost_inventory_info, read again when it changes
+	InventoryFile string
 }
 
+// DefaultTopHostsBy is the field hosts are ranked by when TopHosts is set
+const DefaultTopHostsBy = "REQ_PER_SEC"
+
+// Ways of handling malformed report lines
+const (
+	// ParseModeStrict rejects the whole report
+	ParseModeStrict = "strict"
+	// ParseModeLenient only skips the malformed line
+	ParseModeLenient = "lenient"
+)
+
+// scrapePhases accumulates the time spent in every phase of a scrape: glob,
+// read, parse, aggregate and emit
+type scrapePhases map[string]time.Duration
+
+func (p scrapePhases) since(phase string, start time.Time) {
+	p[phase] += time.Since(start)
+}
+
+// parseErrorKey identifies the parse errors counted by file, section and reason
+type parseErrorKey struct {
+	file, section, reason string
+}
+
+// scrapeStats accounts for a read of the reports. The scrapes commit it to
+// the exporter metrics once done, the samples dropping it.
+type scrapeStats struct {
+	phases scrapePhases
+	// globbed tells whether the reports could be listed
+	globbed        bool
+	filesMatched   int
+	parseDurations map[string]time.Duration
+	bytesRead      int64
+	incomplete     map[string]int
+	parseErrors    map[parseErrorKey]int
+	failures       int
+	// openErr is the last error opening a report
+	openErr error
+	// complete tells whether every report found was parsed and fresh
+	complete bool
+}
+
+func newScrapeStats() *scrapeStats {
+	return &scrapeStats{
+		phases:         scrapePhases{},
+		parseDurations: make(map[string]time.Duration),
+		incomplete:     make(map[string]int),
+		parseErrors:    make(map[parseErrorKey]int),
+	}
+}
+
+// DefaultPIDFile is where lshttpd writes its PID by default
+const DefaultPIDFile = "/tmp/lshttpd/lshttpd.pid"
+
+// maxIncompleteRetryBackoff bounds the wait between two reads of an incomplete report
+const maxIncompleteRetryBackoff = time.Second
+
 // LitespeedCollector collects LiteSpeed stats from the given files and exports them as Prometheus metrics
 type LitespeedCollector struct {
 	mutex                        sync.RWMutex
 	options                      LitespeedCollectorOpts
 	totalScrapes, scrapeFailures prometheus.Counter
-	logger                       log.Logger
+	restarts                     prometheus.Counter
+	incompleteReports            *prometheus.CounterVec
+	parseErrors                  *prometheus.CounterVec
+	counterResets                *prometheus.CounterVec
+	phaseDuration                *prometheus.HistogramVec
+	fileParseDuration            *prometheus.GaugeVec
+	filesMatched                 prometheus.Gauge
+	bytesRead                    prometheus.Counter
+	lastSuccess                  prometheus.Gauge
+	sampledMetrics               map[string]sampledMetric
+	samples                      map[sampleKey]*sampleStats
+	intervalScraped              bool
+	counters                     *counterTracker
+	handlers                     []string
+	hostnames                    []string
+	// reqRateHosts and extAppHosts rank the hosts when TopHosts is set,
+	// topReqRates and topExtApps being the top ones of the last scrape
+	reqRateHosts, extAppHosts *hostRanker
+	topReqRates, topExtApps   map[string]bool
+	lastUptime                float64
+	uptimeScraped             bool
+	unknownMetrics            metrics
+	userMetrics               metrics
+	passwd                    *passwdFile
+	inventory                 *inventoryFile
+	parser                    rtreport.Parser
+	source                    reportSource
+	logger                    log.Logger
 }
 
 // NewLitespeedCollector returns constructed collector
 func NewLitespeedCollector(opts LitespeedCollectorOpts, logger log.Logger) *LitespeedCollector {
-	return &LitespeedCollector{
+	c := &LitespeedCollector{
 		options: opts,
 		totalScrapes: prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: namespace,
@@ -50,8 +188,104 @@ func NewLitespeedCollector(opts LitespeedCollectorOpts, logger log.Logger) *Lite
 			Name:      "exporter_scrape_failures_total",
 			Help:      "Number of errors while scraping files.",
 		}),
-		logger: logger,
+		restarts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "restarts_total",
+			Help:      "Number of LiteSpeed restarts detected by the uptime dropping between scrapes.",
+		}),
+		incompleteReports: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "exporter_incomplete_reports_total",
+			Help:      "Number of partially written reports read, by file.",
+		}, []string{"file"}),
+		parseErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "exporter_parse_errors_total",
+			Help:      "Number of malformed lines and values found in reports, by file, section and reason.",
+		}, []string{"file", "section", "reason"}),
+		counterResets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "exporter_counter_resets_total",
+			Help:      "Number of counter resets absorbed while summing up reports, by file.",
+		}, []string{"file"}),
+		phaseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "exporter_scrape_phase_duration_seconds",
+			Help:      "Time spent in every phase of a scrape, by phase.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+		}, []string{"phase"}),
+		fileParseDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "exporter_file_parse_duration_seconds",
+			Help:      "Time spent parsing the report during the last scrape, by file.",
+		}, []string{"file"}),
+		filesMatched: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "exporter_files_matched",
+			Help:      "Number of reports found during the last scrape.",
+		}),
+		bytesRead: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "exporter_read_bytes_total",
+			Help:      "Number of bytes read from reports.",
+		}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "exporter_last_successful_scrape_timestamp_seconds",
+			Help:      "Time of the last scrape that found and parsed reports without errors, since unix epoch in seconds.",
+		}),
+		counters:       newCounterTracker(),
+		unknownMetrics: metrics{},
+		userMetrics:    newUserMetrics(),
+		logger:         logger,
+	}
+
+	passwdPath := opts.PasswdFile
+	if passwdPath == "" {
+		passwdPath = DefaultPasswdFile
 	}
+	c.passwd = &passwdFile{path: passwdPath}
+
+	if opts.SampleInterval > 0 {
+		var ignored []string
+		c.sampledMetrics, ignored = newSampledMetrics(opts.SampledMetrics)
+		if len(ignored) > 0 {
+			level.Warn(logger).Log("msg", "Only known gauge fields can be sampled, ignoring some", "fields", strings.Join(ignored, ","))
+		}
+	}
+	c.samples = make(map[sampleKey]*sampleStats)
+
+	if c.options.TopHostsBy == "" {
+		c.options.TopHostsBy = DefaultTopHostsBy
+	}
+	c.reqRateHosts = newHostRanker(opts.TopHostsWindow)
+	c.extAppHosts = newHostRanker(opts.TopHostsWindow)
+
+	if opts.InventoryFile != "" {
+		c.inventory = newInventoryFile(opts.InventoryFile)
+		c.refreshInventory()
+	}
+
+	c.parser = rtreport.Parser{
+		SkipHostReqRates: !opts.ReqRatesByHost,
+		SkipExtApps:      opts.ExcludeExtapp,
+		KeepBlockedIPs:   opts.BlockedIPsTopN > 0,
+		Lenient:          opts.ParseMode == ParseModeLenient,
+		SkipReqRate:      c.skipReqRate,
+		SkipExtApp:       c.skipExtApp,
+	}
+
+	if opts.Source == SourceWebAdmin {
+		c.source = newWebAdminSource(opts.WebAdminURL, opts.WebAdminUsername, opts.WebAdminPassword, opts.WebAdminInsecure)
+	} else {
+		pidFile := opts.PIDFile
+		if pidFile == "" {
+			pidFile = DefaultPIDFile
+		}
+		c.source = &fileSource{pattern: opts.FilePattern, pidFile: pidFile}
+	}
+
+	return c
 }
 
 func (c *LitespeedCollector) metricIsTracked(flag string) bool {
@@ -59,17 +293,142 @@ func (c *LitespeedCollector) metricIsTracked(flag string) bool {
 	return !ok
 }
 
+// handleParseError reports the lines and values the parser skipped in lenient mode
+func (c *LitespeedCollector) handleParseError(s *scrapeStats, err error) {
+	var pe *rtreport.ParseError
+	if !errors.As(err, &pe) {
+		level.Error(c.logger).Log("msg", "Can't parse report", "err", err)
+		s.failures++
+		return
+	}
+
+	if pe.Reason == "invalid_value" {
+		c.logParseError(level.Error(c.logger), "Can't parse field value", pe, s)
+		s.failures++
+		return
+	}
+	c.logParseError(level.Warn(c.logger), "Skipping malformed line", pe, s)
+}
+
+// logParseError logs the diagnostic of a parse error and counts it by file,
+// section and reason
+func (c *LitespeedCollector) logParseError(logger log.Logger, msg string, pe *rtreport.ParseError, s *scrapeStats) {
+	keyvals := []interface{}{"msg", msg, "file", pe.File, "line", pe.Line, "section", pe.Section, "reason", pe.Reason}
+	if pe.Err != nil {
+		keyvals = append(keyvals, "err", pe.Err)
+	}
+	logger.Log(keyvals...)
+
+	s.parseErrors[parseErrorKey{pe.File, pe.Section, pe.Reason}]++
+}
+
+// skipField tells the parser to drop the fields that are excluded or can't
+// be exported, counting the unknown fields whose names aren't valid metric names
+func (c *LitespeedCollector) skipField(s *scrapeStats, file, flag string) bool {
+	if !c.metricIsTracked(flag) {
+		return true
+	}
+	if _, ok := LitespeedMetrics[flag]; ok {
+		return false
+	}
+	if !c.options.ExportUnknownMetrics {
+		return true
+	}
+	if !isValidUnknownField(flag) {
+		level.Debug(c.logger).Log("msg", "Skipping unknown field with an invalid name", "file", file, "field", flag)
+		s.parseErrors[parseErrorKey{file, fieldLabelSet(flag), "invalid_name"}]++
+		return true
+	}
+	return false
+}
+
+// lookupMetric returns how a field is exported, describing unknown fields on
+// the fly when auto-discovery is enabled
+func (c *LitespeedCollector) lookupMetric(flag string) (metricInfo, bool) {
+	if metric, ok := LitespeedMetrics[flag]; ok {
+		return metric, true
+	}
+	if !c.options.ExportUnknownMetrics {
+		return metricInfo{}, false
+	}
+
+	metric, ok := c.unknownMetrics[flag]
+	if !ok {
+		if metric, ok = newUnknownMetric(flag); !ok {
+			return metricInfo{}, false
+		}
+		c.unknownMetrics[flag] = metric
+	}
+	return metric, true
+}
+
 // Describe describes all the metrics that can be exported by the LiteSpeed exporter
 func (c *LitespeedCollector) Describe(ch chan<- *prometheus.Desc) {
+	// Unknown fields are only discovered while collecting, so the collector
+	// has to be registered as unchecked by describing nothing
+	if c.options.ExportUnknownMetrics {
+		return
+	}
+
 	for flag, metric := range LitespeedMetrics {
-		if c.metricIsTracked(flag) {
-			ch <- metric.Desc
+		if !c.metricIsTracked(flag) {
+			continue
+		}
+		if c.options.HandlerUsers && fieldLabelSet(flag) == extappLabelSet {
+			metric = c.userMetrics[flag]
+		}
+		ch <- metric.Desc
+	}
+	if c.options.LegacyMetricNames {
+		for flag, metric := range legacyMetrics {
+			if c.metricIsTracked(flag) {
+				ch <- metric.Desc
+			}
 		}
 	}
 	ch <- litespeedVersion
+	ch <- litespeedBuildInfo
+	ch <- litespeedMismatch
 	ch <- litespeedUp
+	ch <- litespeedUptime
+	if c.options.BlockedIPsTopN > 0 {
+		ch <- litespeedBlockedIP
+	}
+	if c.inventory != nil {
+		ch <- c.inventory.desc
+	}
+	ch <- litespeedReportAge
+	if c.options.StaleAfter > 0 {
+		ch <- litespeedStale
+	}
+	for _, metric := range c.sampledMetrics {
+		ch <- metric.min.Desc
+		ch <- metric.max.Desc
+		ch <- metric.avg.Desc
+		ch <- metric.last.Desc
+	}
+	if c.options.HandlerUsers {
+		ch <- litespeedUserExtappConnections
+		ch <- litespeedUserExtappWaitQueue
+		ch <- litespeedUserExtappRequests
+	}
+	if c.options.ExportRatios {
+		ch <- litespeedConnectionUtilization
+		ch <- litespeedCacheHitRatio
+		ch <- litespeedExtappUtilization
+		ch <- litespeedExtappQueued
+	}
 	ch <- c.totalScrapes.Desc()
 	ch <- c.scrapeFailures.Desc()
+	ch <- c.restarts.Desc()
+	c.incompleteReports.Describe(ch)
+	c.parseErrors.Describe(ch)
+	c.counterResets.Describe(ch)
+	c.phaseDuration.Describe(ch)
+	c.fileParseDuration.Describe(ch)
+	ch <- c.filesMatched.Desc()
+	ch <- c.bytesRead.Desc()
+	ch <- c.lastSuccess.Desc()
 }
 
 // Collect fetches the stats from target files and delivers them as Prometheus metrics
@@ -77,21 +436,52 @@ func (c *LitespeedCollector) Collect(ch chan<- prometheus.Metric) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	up := getUpStatus("/tmp/lshttpd/lshttpd.pid")
 	c.collectReports(ch)
+	c.collectSampledMetrics(ch)
 
-	ch <- prometheus.MustNewConstMetric(litespeedUp, prometheus.GaugeValue, up)
+	ch <- prometheus.MustNewConstMetric(litespeedUp, prometheus.GaugeValue, c.source.up())
 	ch <- c.totalScrapes
 	ch <- c.scrapeFailures
+	ch <- c.restarts
+	c.incompleteReports.Collect(ch)
+	c.parseErrors.Collect(ch)
+	c.counterResets.Collect(ch)
+	c.phaseDuration.Collect(ch)
+	c.fileParseDuration.Collect(ch)
+	ch <- c.filesMatched
+	ch <- c.bytesRead
+	ch <- c.lastSuccess
 }
 
-func getUpStatus(pidFile string) float64 {
+// Handlers returns the names of the external application handlers found in
+// the reports during the last scrape
+func (c *LitespeedCollector) Handlers() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.handlers
+}
+
+// Hostnames returns the names of the virtual hosts found in the REQ_RATE
+// lines of the reports during the last scrape
+func (c *LitespeedCollector) Hostnames() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.hostnames
+}
+
+// readPIDFile returns the PID of the main lshttpd process
+func readPIDFile(pidFile string) (int, error) {
 	data, err := ioutil.ReadFile(pidFile)
 	if err != nil {
-		return 0
+		return 0, err
 	}
+	return strconv.Atoi(string(bytes.TrimSpace(data)))
+}
 
-	pid, err := strconv.Atoi(string(bytes.TrimSpace(data)))
+func getUpStatus(pidFile string) float64 {
+	pid, err := readPIDFile(pidFile)
 	if err != nil {
 		return 0
 	}
@@ -111,14 +501,57 @@ func getUpStatus(pidFile string) float64 {
 
 func (c *LitespeedCollector) collectReports(ch chan<- prometheus.Metric) error {
 	c.totalScrapes.Inc()
+	stats := newScrapeStats()
+	defer c.commitScrape(stats)
 
-	reports, err := c.scrapeReports(c.options.FilePattern)
+	reports, err := c.scrapeReports(stats)
 	if err != nil {
-		c.scrapeFailures.Inc()
+		stats.failures++
 		return err
 	}
 
+	start := time.Now()
+	c.collectFreshnessMetrics(reports, ch)
+	// Stale reports count as failures, the scrape only succeeding when every
+	// report found is exported
+	stats.complete = len(reports) > 0 && len(reports) == stats.filesMatched
+	c.handlers = reportHandlers(reports)
+	c.hostnames = reportHostnames(reports)
+	if len(reports) == 0 {
+		stats.phases.since("aggregate", start)
+		return nil
+	}
+	if c.options.HandlerUsers {
+		c.refreshPasswd()
+	}
+	if c.inventory != nil {
+		c.refreshInventory()
+	}
+
+	// Versions are compared across cores before the reports get summed up
+	c.collectVersionMetrics(reports, ch)
+	// Hosts are folded before the reports get summed up, the counters being
+	// tracked by host so that the __other__ ones don't drop
+	if c.options.TopHosts > 0 {
+		c.rankHosts(reports)
+	}
+	if c.countersTracked() {
+		c.observeReports(reports)
+	}
+	if c.options.TopHosts > 0 {
+		c.foldHosts(reports)
+	}
+	if !c.options.MetricsByCore {
+		reports = map[string]rtreport.Report{"": *sumReports(reports)}
+	}
+	if c.countersTracked() {
+		c.replaceCounters(reports)
+	}
+	stats.phases.since("aggregate", start)
+
+	start = time.Now()
 	versionScraped := false
+	uptime, uptimeScraped := 0.0, false
 
 	for core, report := range reports {
 		if !versionScraped {
@@ -126,185 +559,314 @@ func (c *LitespeedCollector) collectReports(ch chan<- prometheus.Metric) error {
 			versionScraped = true
 		}
 
+		if u, ok := c.collectUptimeMetric(core, report.GeneralInfo, ch); ok {
+			if !uptimeScraped || u > uptime {
+				uptime = u
+			}
+			uptimeScraped = true
+		}
+
 		c.collectGeneralInfoMetrics(core, report.GeneralInfo, ch)
 		c.collectReqRateMetrics(core, report.ReqRates, ch)
 		c.collectExtAppMetrics(core, report.ExtApps, ch)
+		if c.options.ExportRatios {
+			c.collectRatioMetrics(core, report, ch)
+		}
+	}
+
+	if c.inventory != nil {
+		c.collectVhostInventoryInfo(reports, ch)
 	}
 
+	if uptimeScraped {
+		c.trackRestarts(uptime)
+	}
+	stats.phases.since("emit", start)
 	return nil
 }
 
-func (c *LitespeedCollector) collectGeneralInfoMetrics(core string, generalInfo generalInfoReport, ch chan<- prometheus.Metric) {
-	for flag, value := range generalInfo.KeyValues {
-		if metric, ok := LitespeedMetrics[flag]; ok {
-			ch <- prometheus.MustNewConstMetric(metric.Desc, metric.Type, value, core)
+// commitScrape records the time spent in every phase of a scrape along with
+// what was read and the errors found
+func (c *LitespeedCollector) commitScrape(s *scrapeStats) {
+	for phase, d := range s.phases {
+		c.phaseDuration.WithLabelValues(phase).Observe(d.Seconds())
+	}
+	if s.globbed {
+		c.filesMatched.Set(float64(s.filesMatched))
+		c.fileParseDuration.Reset()
+		for name, d := range s.parseDurations {
+			c.fileParseDuration.WithLabelValues(name).Set(d.Seconds())
 		}
 	}
+	c.bytesRead.Add(float64(s.bytesRead))
+	for name, n := range s.incomplete {
+		c.incompleteReports.WithLabelValues(name).Add(float64(n))
+	}
+	for key, n := range s.parseErrors {
+		c.parseErrors.WithLabelValues(key.file, key.section, key.reason).Add(float64(n))
+	}
+	c.scrapeFailures.Add(float64(s.failures))
+	c.source.scraped(s.openErr)
+	if s.complete {
+		c.lastSuccess.SetToCurrentTime()
+	}
 }
 
-func (c *LitespeedCollector) collectReqRateMetrics(core string, reports []requestRateReport, ch chan<- prometheus.Metric) {
-	for _, rrReport := range reports {
-		for flag, value := range rrReport.KeyValues {
-			if metric, ok := LitespeedMetrics[flag]; ok {
-				ch <- prometheus.MustNewConstMetric(metric.Desc, metric.Type, value, core, rrReport.Hostname)
-			}
+// collectFreshnessMetrics exports the age of every report, dropping the
+// stale ones so that a wedged LiteSpeed doesn't keep serving frozen numbers
+func (c *LitespeedCollector) collectFreshnessMetrics(reports map[string]rtreport.Report, ch chan<- prometheus.Metric) {
+	now := time.Now()
+	for core := range reports {
+		modTime, ok := c.source.modTime(core)
+		if !ok {
+			continue
 		}
+
+		age := now.Sub(modTime)
+		ch <- prometheus.MustNewConstMetric(litespeedReportAge, prometheus.GaugeValue, age.Seconds(), core)
+		if c.options.StaleAfter <= 0 {
+			continue
+		}
+
+		stale := 0.0
+		if age > c.options.StaleAfter {
+			level.Warn(c.logger).Log("msg", "Skipping stale report", "file", core, "age", age)
+			delete(reports, core)
+			stale = 1
+		}
+		ch <- prometheus.MustNewConstMetric(litespeedStale, prometheus.GaugeValue, stale, core)
 	}
 }
 
-func (c *LitespeedCollector) collectExtAppMetrics(core string, reports []externalAppReport, ch chan<- prometheus.Metric) {
-	for _, eaReport := range reports {
-		for flag, value := range eaReport.KeyValues {
-			if metric, ok := LitespeedMetrics[flag]; ok {
-				ch <- prometheus.MustNewConstMetric(metric.Desc, metric.Type, value, core, eaReport.Service, eaReport.Hostname, eaReport.Handler)
-			}
+// collectVersionMetrics exports the build info of every version found in the
+// reports, flagging a mismatch when they differ
+func (c *LitespeedCollector) collectVersionMetrics(reports map[string]rtreport.Report, ch chan<- prometheus.Metric) {
+	versions := make(map[string]bool)
+	for _, report := range reports {
+		if report.GeneralInfo.Version != "" {
+			versions[report.GeneralInfo.Version] = true
 		}
 	}
+
+	for version := range versions {
+		v := rtreport.ParseVersion(version)
+		ch <- prometheus.MustNewConstMetric(litespeedBuildInfo, prometheus.GaugeValue, 1, v.Product, v.Edition, v.Version, v.Major, v.Minor)
+	}
+
+	mismatch := 0.0
+	if len(versions) > 1 {
+		mismatch = 1
+	}
+	ch <- prometheus.MustNewConstMetric(litespeedMismatch, prometheus.GaugeValue, mismatch)
 }
 
-func (c *LitespeedCollector) scrapeFile(fileName string) (report *litespeedReport, err error) {
-	file, err := os.Open(fileName)
+func (c *LitespeedCollector) collectUptimeMetric(core string, generalInfo rtreport.GeneralInfo, ch chan<- prometheus.Metric) (float64, bool) {
+	if generalInfo.Uptime == "" {
+		return 0, false
+	}
+
+	d, err := rtreport.ParseUptime(generalInfo.Uptime)
 	if err != nil {
-		return nil, err
+		level.Error(c.logger).Log("msg", "Can't parse uptime", "value", generalInfo.Uptime, "err", err)
+		c.scrapeFailures.Inc()
+		return 0, false
 	}
+	uptime := d.Seconds()
 
-	defer func() {
-		file.Close()
-		if r := recover(); r != nil {
-			err = fmt.Errorf("failed scraping file: %s", r)
-			report = nil
-		}
-	}()
+	ch <- prometheus.MustNewConstMetric(litespeedUptime, prometheus.GaugeValue, uptime, core)
+	return uptime, true
+}
 
-	idRegex := regexp.MustCompile(`^\w*`)
-	ibRegex := regexp.MustCompile(`\[([^\[\]]*)\]`)
+// trackRestarts counts a LiteSpeed restart whenever the uptime is lower than
+// the one seen during the previous scrape.
+func (c *LitespeedCollector) trackRestarts(uptime float64) {
+	if c.uptimeScraped && uptime < c.lastUptime {
+		level.Info(c.logger).Log("msg", "LiteSpeed restart detected", "uptime", uptime, "previous_uptime", c.lastUptime)
+		c.restarts.Inc()
+	}
 
-	report = &litespeedReport{
-		GeneralInfo: generalInfoReport{KeyValues: make(map[string]float64)},
-		ReqRates:    []requestRateReport{},
-		ExtApps:     []externalAppReport{},
+	c.lastUptime = uptime
+	c.uptimeScraped = true
+}
+
+// collectFieldMetric exports the value of a field, along with its legacy
+// metric when enabled
+func (c *LitespeedCollector) collectFieldMetric(flag string, value float64, ch chan<- prometheus.Metric, labelValues ...string) {
+	if metric, ok := c.lookupMetric(flag); ok {
+		c.sendFieldMetric(flag, metric, metric.value(value), ch, labelValues...)
 	}
-	reader := bufio.NewReader(file)
-	var line string
+	c.collectLegacyMetric(flag, value, ch, labelValues...)
+}
 
-	for {
-		line, err = reader.ReadString('\n')
-		if err != nil {
-			break
+// collectLegacyMetric exports the value of a field under its legacy name when enabled
+func (c *LitespeedCollector) collectLegacyMetric(flag string, value float64, ch chan<- prometheus.Metric, labelValues ...string) {
+	if c.options.LegacyMetricNames {
+		if metric, ok := legacyMetrics[flag]; ok {
+			c.sendFieldMetric(flag, metric, value, ch, labelValues...)
 		}
+	}
+}
+
+// sendFieldMetric sends the metric of a field, the ones that can't be built
+// from what the report holds being counted as failures rather than panicking
+func (c *LitespeedCollector) sendFieldMetric(flag string, metric metricInfo, value float64, ch chan<- prometheus.Metric, labelValues ...string) {
+	m, err := prometheus.NewConstMetric(metric.Desc, metric.Type, value, labelValues...)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "Can't export field", "field", flag, "err", err)
+		c.scrapeFailures.Inc()
+		return
+	}
+	ch <- m
+}
 
-		line = strings.TrimRight(line, "\n")
+func (c *LitespeedCollector) collectGeneralInfoMetrics(core string, generalInfo rtreport.GeneralInfo, ch chan<- prometheus.Metric) {
+	for flag, value := range generalInfo.KeyValues {
+		c.collectFieldMetric(flag, value, ch, core)
+	}
 
-		identifier := idRegex.FindString(line)
-		if identifier == "" {
-			continue
+	if c.options.BlockedIPsTopN > 0 {
+		for _, b := range topBlockedIPs(generalInfo.BlockedIPs, c.options.BlockedIPsTopN, c.options.BlockedIPsByPrefix) {
+			ch <- prometheus.MustNewConstMetric(litespeedBlockedIP, prometheus.GaugeValue, b.Count, core, b.Address)
 		}
+	}
+}
 
-		switch identifier {
-		case versionField:
-			_, v := parseKeyValPair(line, ": ")
-			report.GeneralInfo.Version = v
-		case uptimeField:
-			_, v := parseKeyValPair(line, ": ")
-			report.GeneralInfo.Uptime = v
-		case bpsInField, maxconnField:
-			m := parseKeyValLineToMap(line)
-			for k, v := range m {
-				if !c.metricIsTracked(k) {
-					continue
-				}
-
-				vf, err := parseMetricValue(k, v)
-				if err != nil {
-					level.Error(c.logger).Log("msg", "Can't parse field value", "value", v, "err", err)
-					c.scrapeFailures.Inc()
-				} else {
-					report.GeneralInfo.KeyValues[k] = vf
-				}
-			}
-		case reqRateField:
-			parts := strings.SplitN(line, ": ", 2)
-			matches := ibRegex.FindStringSubmatch(line)
+func (c *LitespeedCollector) collectReqRateMetrics(core string, reports []rtreport.RequestRate, ch chan<- prometheus.Metric) {
+	for _, rrReport := range reports {
+		for flag, value := range rrReport.KeyValues {
+			c.collectFieldMetric(flag, value, ch, core, rrReport.Hostname)
+		}
+	}
+}
 
-			if !c.options.ReqRatesByHost && matches[1] != "" {
-				continue
-			}
+func (c *LitespeedCollector) collectExtAppMetrics(core string, reports []rtreport.ExternalApp, ch chan<- prometheus.Metric) {
+	if c.options.HandlerUsers {
+		c.collectUserExtAppMetrics(core, reports, ch)
+		return
+	}
 
-			m := parseKeyValLineToMap(parts[1])
-			rr := requestRateReport{
-				Hostname:  matches[1],
-				KeyValues: make(map[string]float64),
-			}
-			for k, v := range m {
-				prefixedFlag := reqRateField + "_" + k
-				if !c.metricIsTracked(prefixedFlag) {
-					continue
-				}
-
-				vf, err := parseMetricValue(prefixedFlag, v)
-				if err != nil {
-					level.Error(c.logger).Log("msg", "Can't parse field value", "value", v, "err", err)
-					c.scrapeFailures.Inc()
-				} else {
-					rr.KeyValues[prefixedFlag] = vf
-				}
-			}
-			report.ReqRates = append(report.ReqRates, rr)
-		case extappField:
-			if c.options.ExcludeExtapp {
-				break
-			}
+	for _, eaReport := range reports {
+		for flag, value := range eaReport.KeyValues {
+			c.collectFieldMetric(flag, value, ch, core, eaReport.Service, eaReport.Hostname, eaReport.Handler)
+		}
+	}
+}
 
-			parts := strings.SplitN(line, ": ", 2)
-			m := parseKeyValLineToMap(parts[1])
-			matches := ibRegex.FindAllStringSubmatch(line, -1)
-			er := externalAppReport{
-				Service:   matches[0][1],
-				Hostname:  matches[1][1],
-				Handler:   matches[2][1],
-				KeyValues: make(map[string]float64),
-			}
-			for k, v := range m {
-				prefixedFlag := extappField + "_" + k
-				if !c.metricIsTracked(prefixedFlag) {
-					continue
-				}
-
-				vf, err := parseMetricValue(prefixedFlag, v)
-				if err != nil {
-					level.Error(c.logger).Log("msg", "Can't parse field value", "value", v, "err", err)
-					c.scrapeFailures.Inc()
-				} else {
-					er.KeyValues[prefixedFlag] = vf
-				}
+// collectUserExtAppMetrics exports the EXTAPP fields labeled by the user the
+// handler is spawned for, legacy metrics keeping their previous labels
+func (c *LitespeedCollector) collectUserExtAppMetrics(core string, reports []rtreport.ExternalApp, ch chan<- prometheus.Metric) {
+	for _, eaReport := range reports {
+		user := c.handlerUser(eaReport.Handler)
+		for flag, value := range eaReport.KeyValues {
+			if metric, ok := c.lookupUserMetric(flag); ok {
+				c.sendFieldMetric(flag, metric, metric.value(value), ch, core, eaReport.Service, eaReport.Hostname, eaReport.Handler, user)
 			}
-			report.ExtApps = append(report.ExtApps, er)
+			c.collectLegacyMetric(flag, value, ch, core, eaReport.Service, eaReport.Hostname, eaReport.Handler)
 		}
 	}
 
-	if err != io.EOF {
+	c.collectUserMetrics(core, reports, ch)
+}
+
+// countingReader counts the bytes read from a report and the time spent
+// reading them, which the parser streams through
+type countingReader struct {
+	r        io.Reader
+	n        int64
+	duration time.Duration
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := r.r.Read(p)
+	r.duration += time.Since(start)
+	r.n += int64(n)
+	return n, err
+}
+
+// scrapeReport parses the given report as it's read, the time spent waiting
+// for the report being told apart from the time spent parsing it
+func (c *LitespeedCollector) scrapeReport(name string, s *scrapeStats) (*rtreport.Report, error) {
+	start := time.Now()
+	rc, err := c.source.open(name)
+	s.openErr = err
+	if err != nil {
+		s.phases.since("read", start)
 		return nil, err
 	}
+	defer rc.Close()
+	opened := time.Now()
+	r := &countingReader{r: rc}
+
+	parser := c.parser
+	parser.ErrorHandler = func(err error) { c.handleParseError(s, err) }
+	parser.SkipField = func(flag string) bool { return c.skipField(s, name, flag) }
+	report, err := parser.ParseNamed(r, name)
+
+	parse := time.Since(opened) - r.duration
+	s.phases["read"] += opened.Sub(start) + r.duration
+	s.phases["parse"] += parse
+	s.parseDurations[name] = parse
+	s.bytesRead += r.n
+
+	return report, err
+}
 
-	return report, nil
+// scrapeReportWithRetries scrapes the given report, retrying with a bounded
+// backoff while the report is incomplete
+func (c *LitespeedCollector) scrapeReportWithRetries(name string, s *scrapeStats) (*rtreport.Report, error) {
+	backoff := c.options.IncompleteRetryBackoff
+
+	for attempt := 0; ; attempt++ {
+		report, err := c.scrapeReport(name, s)
+		if err != rtreport.ErrIncomplete {
+			return report, err
+		}
+
+		s.incomplete[name]++
+		if attempt >= c.options.IncompleteRetries {
+			level.Warn(c.logger).Log("msg", "Skipping incomplete report", "file", name, "attempts", attempt+1)
+			return nil, err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxIncompleteRetryBackoff {
+			backoff = maxIncompleteRetryBackoff
+		}
+	}
 }
 
-func (c *LitespeedCollector) scrapeReports(filePattern string) (map[string]litespeedReport, error) {
-	matches, err := filepath.Glob(filePattern)
+// scrapeReports reads and parses the reports, accounting for it in s
+func (c *LitespeedCollector) scrapeReports(s *scrapeStats) (map[string]rtreport.Report, error) {
+	start := time.Now()
+	matches, err := c.source.names()
+	s.phases.since("glob", start)
 	if err != nil {
 		return nil, err
 	}
+	s.globbed = true
+	s.filesMatched = len(matches)
 
-	reports := make(map[string]litespeedReport)
+	reports := make(map[string]rtreport.Report)
 	for _, match := range matches {
-		report, err := c.scrapeFile(match)
+		report, err := c.scrapeReportWithRetries(match, s)
 		if err == nil {
 			reports[match] = *report
+			continue
 		}
-	}
 
-	if !c.options.MetricsByCore {
-		return map[string]litespeedReport{"": *sumReports(reports)}, nil
+		var pe *rtreport.ParseError
+		switch {
+		case err == rtreport.ErrIncomplete:
+			// Already reported while retrying
+		case errors.As(err, &pe):
+			c.logParseError(level.Error(c.logger), "Rejecting malformed report", pe, s)
+			s.failures++
+		default:
+			level.Error(c.logger).Log("msg", "Can't scrape report", "file", match, "err", err)
+			s.failures++
+		}
 	}
 
 	return reports, nil
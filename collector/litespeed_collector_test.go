@@ -5,11 +5,15 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/hostinger/litespeed_exporter/rtreport"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,11 +22,38 @@ func assertMetricsEqual(t *testing.T, c prometheus.Collector, expected string) {
 	if err != nil {
 		t.Fatalf("Error opening expected result file %q: %v", expected, err)
 	}
-	if err := testutil.CollectAndCompare(c, exp); err != nil {
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatal("Can't register collector:", err)
+	}
+	if err := testutil.GatherAndCompare(withoutVolatileMetrics(reg), exp); err != nil {
 		t.Fatal("Metrics not equal:", err)
 	}
 }
 
+// volatileMetrics depend on when the test data was checked out or on how
+// long the test takes
+var volatileMetrics = map[string]bool{
+	"litespeed_report_age_seconds":                                true,
+	"litespeed_exporter_scrape_phase_duration_seconds":            true,
+	"litespeed_exporter_file_parse_duration_seconds":              true,
+	"litespeed_exporter_last_successful_scrape_timestamp_seconds": true,
+}
+
+func withoutVolatileMetrics(g prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+		filtered := mfs[:0]
+		for _, mf := range mfs {
+			if !volatileMetrics[mf.GetName()] {
+				filtered = append(filtered, mf)
+			}
+		}
+		return filtered, err
+	})
+}
+
 func TestScrapeReportsHandlesZeroMatchingFiles(t *testing.T) {
 	c := NewLitespeedCollector(
 		LitespeedCollectorOpts{
@@ -34,10 +65,10 @@ func TestScrapeReportsHandlesZeroMatchingFiles(t *testing.T) {
 		},
 		log.NewNopLogger(),
 	)
-	r, err := c.scrapeReports(c.options.FilePattern)
+	r, err := c.scrapeReports(newScrapeStats())
 
 	assert.Nil(t, err)
-	assert.Equal(t, map[string]litespeedReport{}, r)
+	assert.Equal(t, map[string]rtreport.Report{}, r)
 }
 
 func TestScrapeReportsHandlesMalformedReportFile(t *testing.T) {
@@ -51,10 +82,43 @@ func TestScrapeReportsHandlesMalformedReportFile(t *testing.T) {
 		},
 		log.NewNopLogger(),
 	)
-	r, err := c.scrapeReports(c.options.FilePattern)
+	stats := newScrapeStats()
+	r, err := c.scrapeReports(stats)
+	c.commitScrape(stats)
 
 	assert.Len(t, r, 0)
 	assert.Nil(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.parseErrors.WithLabelValues(c.options.FilePattern, "req_rate", "missing_hostname")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.scrapeFailures))
+}
+
+func TestScrapeReportsSkipsMalformedLinesWhenLenient(t *testing.T) {
+	f, _ := ioutil.TempFile("", "TestScrapeReportsSkipsMalformedLinesWhenLenient")
+	defer os.Remove(f.Name())
+	ioutil.WriteFile(f.Name(), []byte("VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, BPS_OUT: 2\nREQ_RATE: REQ_PROCESSING: 0\n"+
+		"EXTAPP [LSAPI] [localhost] [lsphp.10000]: CMAXCONN: 10\nEOF\n"), 0644)
+
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     f.Name(),
+			ReqRatesByHost:  false,
+			MetricsByCore:   true,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			ParseMode:       ParseModeLenient,
+		},
+		log.NewNopLogger(),
+	)
+	stats := newScrapeStats()
+	r, err := c.scrapeReports(stats)
+	c.commitScrape(stats)
+
+	assert.Nil(t, err)
+	assert.Len(t, r, 1)
+	assert.Len(t, r[f.Name()].ReqRates, 0)
+	assert.Len(t, r[f.Name()].ExtApps, 1)
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.parseErrors.WithLabelValues(f.Name(), "req_rate", "missing_hostname")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.scrapeFailures))
 }
 
 func TestScrapeReportsHandlesMatchingFiles(t *testing.T) {
@@ -68,11 +132,71 @@ func TestScrapeReportsHandlesMatchingFiles(t *testing.T) {
 		},
 		log.NewNopLogger(),
 	)
-	r, err := c.scrapeReports(c.options.FilePattern)
+	r, err := c.scrapeReports(newScrapeStats())
 
 	assert.Len(t, r, 3)
 	assert.Nil(t, err)
 }
+
+func TestScrapeReportDetectsIncompleteReports(t *testing.T) {
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     path.Join("..", "testdata", "incomplete_report"),
+			ReqRatesByHost:  false,
+			MetricsByCore:   true,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+		},
+		log.NewNopLogger(),
+	)
+
+	tests := []struct {
+		content string
+		want    error
+	}{
+		{"VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, BPS_OUT: 2\nEOF\n", nil},
+		{"VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, BPS_OUT: 2\nEOF", nil},
+		{"VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, BPS_OUT: 2\n", rtreport.ErrIncomplete},
+		{"BPS_IN: 1, BPS_OUT: 2\nEOF\n", rtreport.ErrIncomplete},
+		{"VERSION: LiteSpeed Web Server/Open/1.6.18\nEOF\n", rtreport.ErrIncomplete},
+		{"", rtreport.ErrIncomplete},
+	}
+
+	f, _ := ioutil.TempFile("", "TestScrapeReportDetectsIncompleteReports")
+	defer os.Remove(f.Name())
+
+	for _, tc := range tests {
+		ioutil.WriteFile(f.Name(), []byte(tc.content), 0644)
+		_, err := c.scrapeReport(f.Name(), newScrapeStats())
+		assert.Equal(t, tc.want, err, "Unexpected error for report %q", tc.content)
+	}
+
+	_, err := c.scrapeReport(c.options.FilePattern, newScrapeStats())
+	assert.Equal(t, rtreport.ErrIncomplete, err)
+}
+
+func TestScrapeReportsRetriesIncompleteReports(t *testing.T) {
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:            path.Join("..", "testdata", "incomplete_report"),
+			ReqRatesByHost:         false,
+			MetricsByCore:          true,
+			ExcludeExtapp:          false,
+			ExcludedMetrics:        ParseFlagsToMap([]string{}),
+			IncompleteRetries:      2,
+			IncompleteRetryBackoff: time.Millisecond,
+		},
+		log.NewNopLogger(),
+	)
+	stats := newScrapeStats()
+	r, err := c.scrapeReports(stats)
+	c.commitScrape(stats)
+
+	assert.Nil(t, err)
+	assert.Len(t, r, 0)
+	assert.Equal(t, 3.0, testutil.ToFloat64(c.incompleteReports.WithLabelValues(c.options.FilePattern)))
+}
+
 func TestMetricIsTrackedReturnsExpected(t *testing.T) {
 	c := NewLitespeedCollector(
 		LitespeedCollectorOpts{
@@ -107,6 +231,7 @@ func TestCollectHandlesInvalidReportValueTypes(t *testing.T) {
 			MetricsByCore:   true,
 			ExcludeExtapp:   false,
 			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			ParseMode:       ParseModeLenient,
 		},
 		log.NewNopLogger(),
 	)
@@ -114,6 +239,28 @@ func TestCollectHandlesInvalidReportValueTypes(t *testing.T) {
 	assertMetricsEqual(t, c, "invalid_value_types.metrics")
 }
 
+func TestScrapeReportsRejectsInvalidValuesWhenStrict(t *testing.T) {
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     path.Join("..", "testdata", "invalid_value_types_report"),
+			ReqRatesByHost:  false,
+			MetricsByCore:   true,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			ParseMode:       ParseModeStrict,
+		},
+		log.NewNopLogger(),
+	)
+	stats := newScrapeStats()
+	r, err := c.scrapeReports(stats)
+	c.commitScrape(stats)
+
+	assert.Nil(t, err)
+	assert.Len(t, r, 0)
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.parseErrors.WithLabelValues(c.options.FilePattern, "general", "invalid_value")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.scrapeFailures))
+}
+
 func TestCollectSkipsExcludedMetricsWhenAllExcluded(t *testing.T) {
 	var ef []string
 	for flag := range LitespeedMetrics {
@@ -222,6 +369,377 @@ func TestCollectSkipsExtappMetrics(t *testing.T) {
 	assertMetricsEqual(t, c, "extapp_excluded.metrics")
 }
 
+func TestCollectExportsTopBlockedIPPrefixes(t *testing.T) {
+	var ef []string
+	for flag := range LitespeedMetrics {
+		if flag != blockedIPField {
+			ef = append(ef, flag)
+		}
+	}
+
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:        path.Join("..", "testdata", "blocked_ips_report"),
+			ReqRatesByHost:     false,
+			MetricsByCore:      true,
+			ExcludeExtapp:      false,
+			ExcludedMetrics:    ParseFlagsToMap(ef),
+			BlockedIPsTopN:     2,
+			BlockedIPsByPrefix: true,
+		},
+		log.NewNopLogger(),
+	)
+
+	assertMetricsEqual(t, c, "blocked_ips_top.metrics")
+}
+
+func TestCollectExportsUnknownMetrics(t *testing.T) {
+	var ef []string
+	for flag := range LitespeedMetrics {
+		ef = append(ef, flag)
+	}
+
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:          path.Join("..", "testdata", "unknown_fields_report"),
+			ReqRatesByHost:       false,
+			MetricsByCore:        true,
+			ExcludeExtapp:        false,
+			ExcludedMetrics:      ParseFlagsToMap(ef),
+			ExportUnknownMetrics: true,
+		},
+		log.NewNopLogger(),
+	)
+
+	assertMetricsEqual(t, c, "unknown_fields.metrics")
+}
+
+func TestCollectSkipsUnknownFieldsWithInvalidNames(t *testing.T) {
+	f, _ := ioutil.TempFile("", "TestCollectSkipsUnknownFieldsWithInvalidNames")
+	defer os.Remove(f.Name())
+	ioutil.WriteFile(f.Name(), []byte("VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, Some-Key: 3, SOME_KEY: 4\nEOF\n"), 0644)
+
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:          f.Name(),
+			MetricsByCore:        true,
+			ExcludedMetrics:      ParseFlagsToMap([]string{}),
+			ExportUnknownMetrics: true,
+		},
+		log.NewNopLogger(),
+	)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	mfs, err := reg.Gather()
+
+	assert.NoError(t, err)
+	var names []string
+	for _, mf := range mfs {
+		names = append(names, mf.GetName())
+	}
+	assert.Contains(t, names, "litespeed_unknown_some_key")
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.parseErrors.WithLabelValues(f.Name(), "general", "invalid_name")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.scrapeFailures))
+}
+
+func TestCollectExportsLegacyMetricNames(t *testing.T) {
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:       path.Join("..", "testdata", ".rtreport"),
+			ReqRatesByHost:    false,
+			MetricsByCore:     true,
+			ExcludeExtapp:     false,
+			ExcludedMetrics:   ParseFlagsToMap([]string{}),
+			LegacyMetricNames: true,
+		},
+		log.NewNopLogger(),
+	)
+
+	assertMetricsEqual(t, c, "legacy_metric_names.metrics")
+}
+
+func TestCollectExportsRatios(t *testing.T) {
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     path.Join("..", "testdata", ".rtreport"),
+			ReqRatesByHost:  true,
+			MetricsByCore:   true,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			ExportRatios:    true,
+		},
+		log.NewNopLogger(),
+	)
+
+	assertMetricsEqual(t, c, "ratios.metrics")
+}
+
+func TestCollectLabelsHandlerUsers(t *testing.T) {
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     path.Join("..", "testdata", ".rtreport"),
+			ReqRatesByHost:  false,
+			MetricsByCore:   true,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			HandlerUsers:    true,
+			PasswdFile:      path.Join("..", "testdata", "passwd"),
+		},
+		log.NewNopLogger(),
+	)
+
+	assertMetricsEqual(t, c, "handler_users.metrics")
+}
+
+func TestCollectFlagsVersionMismatch(t *testing.T) {
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     path.Join("..", "testdata", "upgrade_report.*"),
+			ReqRatesByHost:  false,
+			MetricsByCore:   false,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+		},
+		log.NewNopLogger(),
+	)
+
+	assertMetricsEqual(t, c, "version_mismatch.metrics")
+}
+
+func TestCollectCountsRestartsWhenUptimeDrops(t *testing.T) {
+	f, _ := ioutil.TempFile("", "TestCollectCountsRestartsWhenUptimeDrops")
+	defer os.Remove(f.Name())
+
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     f.Name(),
+			ReqRatesByHost:  false,
+			MetricsByCore:   false,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+		},
+		log.NewNopLogger(),
+	)
+
+	tests := []struct {
+		uptime string
+		want   float64
+	}{
+		{"1 day 00:00:00", 0},
+		{"1 day 00:00:10", 0},
+		{"00:00:05", 1},
+		{"00:00:15", 1},
+		{"00:00:01", 2},
+	}
+
+	for _, tc := range tests {
+		ioutil.WriteFile(f.Name(), []byte("VERSION: LiteSpeed Web Server/Open/1.6.18\nUPTIME: "+tc.uptime+"\nBPS_IN: 1, BPS_OUT: 2\nEOF\n"), 0644)
+		testutil.CollectAndCount(c)
+		assert.Equal(t, tc.want, testutil.ToFloat64(c.restarts))
+	}
+}
+
+func TestCollectKeepsSummedCountersMonotonic(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "TestCollectKeepsSummedCountersMonotonic")
+	defer os.RemoveAll(dir)
+
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     path.Join(dir, ".rtreport*"),
+			ReqRatesByHost:  false,
+			MetricsByCore:   false,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+		},
+		log.NewNopLogger(),
+	)
+
+	writeReport := func(name string, totReqs int) {
+		ioutil.WriteFile(path.Join(dir, name), []byte(fmt.Sprintf("VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, BPS_OUT: 2\nREQ_RATE []: REQ_PROCESSING: 0, TOT_REQS: %d\nEOF\n", totReqs)), 0644)
+	}
+
+	tests := []struct {
+		name    string
+		reports map[string]int
+		want    float64
+		resets  float64
+	}{
+		{"both cores", map[string]int{".rtreport": 100, ".rtreport.2": 50}, 150, 0},
+		{"both cores increase", map[string]int{".rtreport": 120, ".rtreport.2": 60}, 180, 0},
+		{"second core restarted", map[string]int{".rtreport": 130, ".rtreport.2": 5}, 195, 1},
+		{"second core gone", map[string]int{".rtreport": 140}, 205, 1},
+		{"second core back", map[string]int{".rtreport": 140, ".rtreport.2": 15}, 215, 1},
+	}
+
+	for _, tc := range tests {
+		os.Remove(path.Join(dir, ".rtreport.2"))
+		for name, totReqs := range tc.reports {
+			writeReport(name, totReqs)
+		}
+
+		expected := fmt.Sprintf(`
+# HELP litespeed_requests_total Total number of requests served.
+# TYPE litespeed_requests_total counter
+litespeed_requests_total{core="",hostname=""} %v
+`, tc.want)
+		if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "litespeed_requests_total"); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		assert.Equal(t, tc.resets, testutil.ToFloat64(c.counterResets.WithLabelValues(path.Join(dir, ".rtreport.2"))), tc.name)
+	}
+}
+
+func TestCollectSkipsStaleReports(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "TestCollectSkipsStaleReports")
+	defer os.RemoveAll(dir)
+
+	fresh, stale := path.Join(dir, ".rtreport"), path.Join(dir, ".rtreport.2")
+	for _, name := range []string{fresh, stale} {
+		ioutil.WriteFile(name, []byte("VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, BPS_OUT: 2\nEOF\n"), 0644)
+	}
+	mtime := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(stale, mtime, mtime)
+
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     path.Join(dir, ".rtreport*"),
+			ReqRatesByHost:  false,
+			MetricsByCore:   true,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			StaleAfter:      time.Minute,
+		},
+		log.NewNopLogger(),
+	)
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+
+	ages := map[string]float64{}
+	for _, mf := range mfs {
+		if mf.GetName() != "litespeed_report_age_seconds" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			ages[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+		}
+	}
+	assert.InDelta(t, 0, ages[fresh], 60)
+	assert.InDelta(t, 7200, ages[stale], 60)
+
+	expected := fmt.Sprintf(`
+# HELP litespeed_network_receive_bytes_per_second Incoming traffic in bytes per second, by connection type.
+# TYPE litespeed_network_receive_bytes_per_second gauge
+litespeed_network_receive_bytes_per_second{core=%[1]q,type="plain"} 1024
+# HELP litespeed_report_stale Whether the report is older than the staleness threshold and left out of the exported metrics.
+# TYPE litespeed_report_stale gauge
+litespeed_report_stale{core=%[1]q} 0
+litespeed_report_stale{core=%[2]q} 1
+`, fresh, stale)
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "litespeed_network_receive_bytes_per_second", "litespeed_report_stale"); err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.lastSuccess), "Scrapes dropping stale reports aren't successful")
+}
+
+func TestCollectSkipsReportMetricsWhenAllReportsAreStale(t *testing.T) {
+	f, _ := ioutil.TempFile("", "TestCollectSkipsReportMetricsWhenAllReportsAreStale")
+	defer os.Remove(f.Name())
+	ioutil.WriteFile(f.Name(), []byte("VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, BPS_OUT: 2\nEOF\n"), 0644)
+	mtime := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(f.Name(), mtime, mtime)
+
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     f.Name(),
+			ReqRatesByHost:  false,
+			MetricsByCore:   false,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			StaleAfter:      time.Minute,
+		},
+		log.NewNopLogger(),
+	)
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+
+	for _, mf := range mfs {
+		switch mf.GetName() {
+		case "litespeed_version", "litespeed_build_info", "litespeed_version_mismatch", "litespeed_network_receive_bytes_per_second":
+			t.Errorf("Unexpected metric %s", mf.GetName())
+		}
+	}
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.lastSuccess))
+}
+
+func TestCollectExportsScrapePhases(t *testing.T) {
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     path.Join("..", "testdata", ".rtreport"),
+			ReqRatesByHost:  false,
+			MetricsByCore:   false,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+		},
+		log.NewNopLogger(),
+	)
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+
+	phases := map[string]uint64{}
+	files := map[string]bool{}
+	lastSuccess := 0.0
+	for _, mf := range mfs {
+		switch mf.GetName() {
+		case "litespeed_exporter_scrape_phase_duration_seconds":
+			for _, m := range mf.GetMetric() {
+				phases[m.GetLabel()[0].GetValue()] = m.GetHistogram().GetSampleCount()
+			}
+		case "litespeed_exporter_file_parse_duration_seconds":
+			for _, m := range mf.GetMetric() {
+				files[m.GetLabel()[0].GetValue()] = true
+			}
+		case "litespeed_exporter_last_successful_scrape_timestamp_seconds":
+			lastSuccess = mf.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+
+	assert.Equal(t, map[string]uint64{"glob": 1, "read": 1, "parse": 1, "aggregate": 1, "emit": 1}, phases)
+	assert.Equal(t, map[string]bool{c.options.FilePattern: true}, files)
+	assert.InDelta(t, float64(time.Now().Unix()), lastSuccess, 60)
+
+	fi, err := os.Stat(c.options.FilePattern)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(fi.Size()), testutil.ToFloat64(c.bytesRead))
+}
+
+func TestCollectDoesNotRecordFailedScrapesAsSuccessful(t *testing.T) {
+	for _, pattern := range []string{"non-existing-pattern", "malformed_report"} {
+		c := NewLitespeedCollector(
+			LitespeedCollectorOpts{
+				FilePattern:     path.Join("..", "testdata", pattern),
+				ReqRatesByHost:  false,
+				MetricsByCore:   false,
+				ExcludeExtapp:   false,
+				ExcludedMetrics: ParseFlagsToMap([]string{}),
+			},
+			log.NewNopLogger(),
+		)
+
+		testutil.CollectAndCount(c)
+		assert.Equal(t, 0.0, testutil.ToFloat64(c.lastSuccess), pattern)
+	}
+}
+
 func TestGetUpStatusHandlesMissingPIDFile(t *testing.T) {
 	pidFile := "/tmp/TestGetUpStatusHandlesMissingPIDFile"
 
@@ -0,0 +1,201 @@
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	generalLabelSet = "general"
+	reqRateLabelSet = "req_rate"
+	extappLabelSet  = "extapp"
+)
+
+// metricMapping declares how a single .rtreport field is exported
+type metricMapping struct {
+	Field  string `yaml:"field"`
+	Name   string `yaml:"name"`
+	Type   string `yaml:"type"`
+	Help   string `yaml:"help"`
+	Unit   string `yaml:"unit"`
+	Labels string `yaml:"labels"`
+	// Scale multiplies the field values, such as 1024 for KB/s exported in
+	// bytes. Overriding a built-in field drops its own conversion.
+	Scale float64 `yaml:"scale"`
+}
+
+type metricMappingFile struct {
+	Metrics []metricMapping `yaml:"metrics"`
+}
+
+// LoadMetricMappings reads the given mapping file and overrides or extends LitespeedMetrics with its entries
+func LoadMetricMappings(fileName string) error {
+	return loadMetricMappings(fileName, LitespeedMetrics)
+}
+
+func loadMetricMappings(fileName string, m metrics) error {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	var f metricMappingFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return fmt.Errorf("can't parse metric mapping file %s: %s", fileName, err)
+	}
+
+	mapped := make(metrics)
+	for i, mapping := range f.Metrics {
+		metric, err := mapping.metricInfo()
+		if err != nil {
+			return fmt.Errorf("invalid metric mapping #%d in %s: %s", i+1, fileName, err)
+		}
+		mapped[mapping.Field] = metric
+	}
+	if err := checkMetricClashes(m, mapped); err != nil {
+		return fmt.Errorf("invalid metric mapping in %s: %s", fileName, err)
+	}
+
+	for field, metric := range mapped {
+		m[field] = metric
+	}
+	return nil
+}
+
+// checkMetricClashes rejects the mapped fields exported under the name of
+// another metric, which would make every scrape fail unless they share its
+// labels and help text but not its label values
+func checkMetricClashes(m, mapped metrics) error {
+	builtin := append(descCollector{}, builtinDescs...)
+	for field, metric := range m {
+		if _, ok := mapped[field]; !ok {
+			builtin = append(builtin, metric.Desc)
+		}
+	}
+
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(builtin); err != nil {
+		return err
+	}
+
+	fields := make([]string, 0, len(mapped))
+	for field := range mapped {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if err := reg.Register(descCollector{mapped[field].Desc}); err != nil {
+			return fmt.Errorf("metric of field %s clashes with another metric: %s", field, err)
+		}
+	}
+	return nil
+}
+
+// descCollector only describes the given descriptors, so that a registry
+// checks their consistency
+type descCollector []*prometheus.Desc
+
+func (c descCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range c {
+		ch <- desc
+	}
+}
+
+func (c descCollector) Collect(ch chan<- prometheus.Metric) {}
+
+func (mm metricMapping) metricInfo() (metricInfo, error) {
+	if mm.Field == "" {
+		return metricInfo{}, fmt.Errorf("missing field name")
+	}
+
+	name := mm.Name
+	if name == "" {
+		name = strings.ToLower(mm.Field)
+	}
+	if mm.Unit != "" && !strings.HasSuffix(name, "_"+mm.Unit) {
+		name += "_" + mm.Unit
+	}
+	if fqName := prometheus.BuildFQName(namespace, "", name); !model.IsValidMetricName(model.LabelValue(fqName)) {
+		return metricInfo{}, fmt.Errorf("invalid metric name %q for field %s", fqName, mm.Field)
+	}
+
+	help := mm.Help
+	if help == "" {
+		help = mm.Field + " metric."
+	}
+
+	var t prometheus.ValueType
+	switch mm.Type {
+	case "", "gauge":
+		t = prometheus.GaugeValue
+	case "counter":
+		t = prometheus.CounterValue
+	case "untyped":
+		t = prometheus.UntypedValue
+	default:
+		return metricInfo{}, fmt.Errorf("unknown metric type %q for field %s", mm.Type, mm.Field)
+	}
+
+	// The label values are the ones of the line the field is parsed from
+	labels := fieldLabelSet(mm.Field)
+	switch mm.Labels {
+	case "", labels:
+	case generalLabelSet, reqRateLabelSet, extappLabelSet:
+		return metricInfo{}, fmt.Errorf("label set %q doesn't match the %q labels of field %s", mm.Labels, labels, mm.Field)
+	default:
+		return metricInfo{}, fmt.Errorf("unknown label set %q for field %s", mm.Labels, mm.Field)
+	}
+
+	var metric metricInfo
+	switch labels {
+	case reqRateLabelSet:
+		metric = newReqRateMetric(name, help, t)
+	case extappLabelSet:
+		metric = newExtappMetric(name, help, t)
+	default:
+		metric = newGenericMetric(name, help, t)
+	}
+	return metric.scaledBy(mm.Scale), nil
+}
+
+// fieldLabelSet infers the label set of a field from the line it's parsed from
+func fieldLabelSet(field string) string {
+	switch {
+	case strings.HasPrefix(field, reqRateField+"_"):
+		return reqRateLabelSet
+	case strings.HasPrefix(field, extappField+"_"):
+		return extappLabelSet
+	}
+	return generalLabelSet
+}
+
+// newUnknownMetric describes a field the exporter doesn't know about, for the
+// auto-discovery mode, telling whether the field makes a valid metric name
+func newUnknownMetric(field string) (metricInfo, bool) {
+	if !isValidUnknownField(field) {
+		return metricInfo{}, false
+	}
+	name := "UNKNOWN_" + field
+	help := "Unknown LiteSpeed field " + field + " exported as is."
+
+	switch fieldLabelSet(field) {
+	case reqRateLabelSet:
+		return newReqRateMetric(name, help, prometheus.GaugeValue), true
+	case extappLabelSet:
+		return newExtappMetric(name, help, prometheus.GaugeValue), true
+	}
+	return newGenericMetric(name, help, prometheus.GaugeValue), true
+}
+
+// isValidUnknownField tells whether an unknown field can be exported under
+// its own name, report keys such as Some-Key making invalid metric names
+func isValidUnknownField(field string) bool {
+	name := prometheus.BuildFQName(namespace, "", strings.ToLower("UNKNOWN_"+field))
+	return model.IsValidMetricName(model.LabelValue(name))
+}
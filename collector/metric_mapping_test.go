@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMetricMappingsOverridesAndExtendsMetrics(t *testing.T) {
+	m := metrics{
+		bpsInField:  newGenericMetric(bpsInField, "BPS_IN metric.", prometheus.GaugeValue),
+		bpsOutField: newGenericMetric(bpsOutField, "BPS_OUT metric.", prometheus.GaugeValue),
+	}
+
+	err := loadMetricMappings(path.Join("..", "testdata", "metric_mapping.yml"), m)
+
+	assert.Nil(t, err)
+	assert.Len(t, m, 4)
+	assert.Equal(t, "Desc{fqName: \"litespeed_network_receive_kilobytes_per_second\", help: \"Incoming traffic in KB/s.\", constLabels: {}, variableLabels: [core]}", m[bpsInField].Desc.String())
+	assert.Equal(t, "Desc{fqName: \"litespeed_bps_out\", help: \"BPS_OUT metric.\", constLabels: {}, variableLabels: [core]}", m[bpsOutField].Desc.String())
+	assert.Equal(t, prometheus.CounterValue, m["REQ_RATE_NEW_FIELD"].Type)
+	assert.Equal(t, "Desc{fqName: \"litespeed_req_rate_new_field_total\", help: \"A field added by a newer LiteSpeed release.\", constLabels: {}, variableLabels: [core hostname]}", m["REQ_RATE_NEW_FIELD"].Desc.String())
+	assert.Equal(t, "Desc{fqName: \"litespeed_cache_size_bytes\", help: \"CACHE_SIZE metric.\", constLabels: {}, variableLabels: [core]}", m["CACHE_SIZE"].Desc.String())
+	assert.Equal(t, 1024.0, m["CACHE_SIZE"].value(1))
+	assert.Equal(t, 1.0, m[bpsInField].value(1))
+}
+
+func TestLoadMetricMappingsHandlesInvalidFiles(t *testing.T) {
+	tests := []string{"non-existing-mapping.yml", "invalid_metric_mapping.yml", ".rtreport"}
+
+	for _, tc := range tests {
+		m := metrics{}
+		err := loadMetricMappings(path.Join("..", "testdata", tc), m)
+
+		assert.Error(t, err)
+		assert.Len(t, m, 0)
+	}
+}
+
+func TestLoadMetricMappingsRejectsClashingNames(t *testing.T) {
+	f, _ := ioutil.TempFile("", "TestLoadMetricMappingsRejectsClashingNames")
+	defer os.Remove(f.Name())
+
+	tests := []struct {
+		mapping string
+		want    string
+	}{
+		{"{field: NEW_FIELD, name: connections}", "metric of field NEW_FIELD clashes with another metric"},
+		{"{field: NEW_FIELD, name: up}", "metric of field NEW_FIELD clashes with another metric"},
+		{"{field: BPS_IN, name: network_transmit_bytes_per_second}", "metric of field BPS_IN clashes with another metric"},
+		{"{field: NEW_FIELD, name: cache_size}\n  - {field: OTHER_FIELD, name: cache_size}", "metric of field OTHER_FIELD clashes with another metric"},
+	}
+
+	for _, tc := range tests {
+		ioutil.WriteFile(f.Name(), []byte("metrics:\n  - "+tc.mapping+"\n"), 0644)
+		m := metrics{}
+		for field, metric := range LitespeedMetrics {
+			m[field] = metric
+		}
+
+		err := loadMetricMappings(f.Name(), m)
+
+		if assert.Error(t, err, "Expected error for mapping %s", tc.mapping) {
+			assert.Contains(t, err.Error(), tc.want)
+		}
+		assert.Equal(t, LitespeedMetrics, m, "Metrics are left alone when a mapping is rejected")
+	}
+}
+
+func TestMetricInfoRejectsInvalidMappings(t *testing.T) {
+	tests := []struct {
+		mapping metricMapping
+		want    string
+	}{
+		{metricMapping{Name: "no_field"}, "missing field name"},
+		{metricMapping{Field: "NEW-FIELD"}, `invalid metric name "litespeed_new-field" for field NEW-FIELD`},
+		{metricMapping{Field: "NEW_FIELD", Name: "new field"}, `invalid metric name "litespeed_new field" for field NEW_FIELD`},
+		{metricMapping{Field: "NEW_FIELD", Type: "histogram"}, `unknown metric type "histogram" for field NEW_FIELD`},
+		{metricMapping{Field: "NEW_FIELD", Labels: "vhost"}, `unknown label set "vhost" for field NEW_FIELD`},
+		{metricMapping{Field: "NEW_FIELD", Labels: reqRateLabelSet}, `label set "req_rate" doesn't match the "general" labels of field NEW_FIELD`},
+		{metricMapping{Field: "REQ_RATE_NEW_FIELD", Labels: extappLabelSet}, `label set "extapp" doesn't match the "req_rate" labels of field REQ_RATE_NEW_FIELD`},
+		{metricMapping{Field: "EXTAPP_NEW_FIELD", Labels: generalLabelSet}, `label set "general" doesn't match the "extapp" labels of field EXTAPP_NEW_FIELD`},
+	}
+
+	for _, tc := range tests {
+		_, err := tc.mapping.metricInfo()
+		assert.EqualError(t, err, tc.want)
+	}
+}
+
+func TestFieldLabelSetReturnsExpected(t *testing.T) {
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{bpsInField, generalLabelSet},
+		{reqRateTotReqsField, reqRateLabelSet},
+		{extappPoolSizeField, extappLabelSet},
+		{"EXTAPPS_NEW_FIELD", generalLabelSet},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, fieldLabelSet(tc.field))
+	}
+}
@@ -9,49 +9,137 @@ import (
 
 const (
 	namespace = "litespeed"
+
+	// kilobytes converts the KB/s LiteSpeed reports traffic in to bytes
+	kilobytes = 1024
 )
 
 var (
-	// TODO normalize the names & descriptions of each metric
-
 	// LitespeedMetrics includes all available LiteSpeed metrics
 	LitespeedMetrics = metrics{
-		bpsInField:                         newGenericMetric(bpsInField, "BPS_IN metric.", prometheus.GaugeValue),
-		bpsOutField:                        newGenericMetric(bpsOutField, "BPS_OUT metric.", prometheus.GaugeValue),
-		sslBpsInField:                      newGenericMetric(sslBpsInField, "SSL_BPS_IN metric.", prometheus.GaugeValue),
-		sslBpsOutField:                     newGenericMetric(sslBpsOutField, "SSL_BPS_OUT metric.", prometheus.GaugeValue),
-		maxconnField:                       newGenericMetric(maxconnField, "MAXCONN metric.", prometheus.GaugeValue),
-		maxsslConnField:                    newGenericMetric(maxsslConnField, "MAXSSL_CONN metric.", prometheus.GaugeValue),
-		plainconnField:                     newGenericMetric(plainconnField, "PLAINCONN metric.", prometheus.GaugeValue),
-		availconnField:                     newGenericMetric(availconnField, "AVAILCONN metric.", prometheus.GaugeValue),
-		idleconnField:                      newGenericMetric(idleconnField, "IDLECONN metric.", prometheus.GaugeValue),
-		sslconnField:                       newGenericMetric(sslconnField, "SSLCONN metric.", prometheus.GaugeValue),
-		availsslField:                      newGenericMetric(availsslField, "AVAILSSL metric.", prometheus.GaugeValue),
-		reqRateReqProcessingField:          newReqRateMetric(reqRateReqProcessingField, "REQ_RATE_REQ_PROCESSING metric.", prometheus.GaugeValue),
-		reqRateReqPerSecField:              newReqRateMetric(reqRateReqPerSecField, "REQ_RATE_REQ_PER_SEC metric.", prometheus.GaugeValue),
-		reqRateTotReqsField:                newReqRateMetric(reqRateTotReqsField, "REQ_RATE_TOT_REQS metric.", prometheus.GaugeValue),
-		reqRatePubCacheHitsPerSecField:     newReqRateMetric(reqRatePubCacheHitsPerSecField, "REQ_RATE_PUB_CACHE_HITS_PER_SEC metric.", prometheus.GaugeValue),
-		reqRateTotalPubCacheHitsField:      newReqRateMetric(reqRateTotalPubCacheHitsField, "REQ_RATE_TOTAL_PUB_CACHE_HITS metric.", prometheus.GaugeValue),
-		reqRatePrivateCacheHitsPerSecField: newReqRateMetric(reqRatePrivateCacheHitsPerSecField, "REQ_RATE_PRIVATE_CACHE_HITS_PER_SEC metric.", prometheus.GaugeValue),
-		reqRateTotalPrivateCacheHitsField:  newReqRateMetric(reqRateTotalPrivateCacheHitsField, "REQ_RATE_TOTAL_PRIVATE_CACHE_HITS metric.", prometheus.GaugeValue),
-		reqRateStaticHitsPerSecField:       newReqRateMetric(reqRateStaticHitsPerSecField, "REQ_RATE_STATIC_HITS_PER_SEC metric.", prometheus.GaugeValue),
-		reqRateTotalStaticHitsField:        newReqRateMetric(reqRateTotalStaticHitsField, "REQ_RATE_TOTAL_STATIC_HITS metric.", prometheus.GaugeValue),
-		extappCmaxconnField:                newExtappMetric(extappCmaxconnField, "EXTAPP_CMAXCONN metric.", prometheus.GaugeValue),
-		extappEmaxconnField:                newExtappMetric(extappEmaxconnField, "EXTAPP_EMAXCONN metric.", prometheus.GaugeValue),
-		extappPoolSizeField:                newExtappMetric(extappPoolSizeField, "EXTAPP_POOL_SIZE metric.", prometheus.GaugeValue),
-		extappInuseConnField:               newExtappMetric(extappInuseConnField, "EXTAPP_INUSE_CONN metric.", prometheus.GaugeValue),
-		extappIdleConnField:                newExtappMetric(extappIdleConnField, "EXTAPP_IDLE_CONN metric.", prometheus.GaugeValue),
-		extappWaitqueDepthField:            newExtappMetric(extappWaitqueDepthField, "EXTAPP_WAITQUE_DEPTH metric.", prometheus.GaugeValue),
-		extappReqPerSecField:               newExtappMetric(extappReqPerSecField, "EXTAPP_REQ_PER_SEC metric.", prometheus.GaugeValue),
-		extappTotReqsField:                 newExtappMetric(extappTotReqsField, "EXTAPP_TOT_REQS metric.", prometheus.GaugeValue),
+		bpsInField:                         newGenericMetric("network_receive_bytes_per_second", "Incoming traffic in bytes per second, by connection type.", prometheus.GaugeValue).withConstLabels(prometheus.Labels{"type": "plain"}).scaledBy(kilobytes),
+		bpsOutField:                        newGenericMetric("network_transmit_bytes_per_second", "Outgoing traffic in bytes per second, by connection type.", prometheus.GaugeValue).withConstLabels(prometheus.Labels{"type": "plain"}).scaledBy(kilobytes),
+		sslBpsInField:                      newGenericMetric("network_receive_bytes_per_second", "Incoming traffic in bytes per second, by connection type.", prometheus.GaugeValue).withConstLabels(prometheus.Labels{"type": "ssl"}).scaledBy(kilobytes),
+		sslBpsOutField:                     newGenericMetric("network_transmit_bytes_per_second", "Outgoing traffic in bytes per second, by connection type.", prometheus.GaugeValue).withConstLabels(prometheus.Labels{"type": "ssl"}).scaledBy(kilobytes),
+		maxconnField:                       newGenericMetric("connections_max", "Maximum number of concurrent connections, by connection type.", prometheus.GaugeValue).withConstLabels(prometheus.Labels{"type": "plain"}),
+		maxsslConnField:                    newGenericMetric("connections_max", "Maximum number of concurrent connections, by connection type.", prometheus.GaugeValue).withConstLabels(prometheus.Labels{"type": "ssl"}),
+		plainconnField:                     newGenericMetric("connections", "Number of open connections, by connection type and state.", prometheus.GaugeValue).withConstLabels(prometheus.Labels{"type": "plain", "state": "active"}),
+		sslconnField:                       newGenericMetric("connections", "Number of open connections, by connection type and state.", prometheus.GaugeValue).withConstLabels(prometheus.Labels{"type": "ssl", "state": "active"}),
+		idleconnField:                      newGenericMetric("connections_idle", "Number of idle connections, all connection types included.", prometheus.GaugeValue),
+		availconnField:                     newGenericMetric("connections_available", "Number of connections that can still be accepted, by connection type.", prometheus.GaugeValue).withConstLabels(prometheus.Labels{"type": "plain"}),
+		availsslField:                      newGenericMetric("connections_available", "Number of connections that can still be accepted, by connection type.", prometheus.GaugeValue).withConstLabels(prometheus.Labels{"type": "ssl"}),
+		reqRateReqProcessingField:          newReqRateMetric("requests_in_progress", "Number of requests being processed.", prometheus.GaugeValue),
+		reqRateReqPerSecField:              newReqRateMetric("requests_per_second", "Number of requests served per second.", prometheus.GaugeValue),
+		reqRateTotReqsField:                newReqRateMetric("requests_total", "Total number of requests served.", prometheus.CounterValue),
+		reqRatePubCacheHitsPerSecField:     newReqRateMetric("public_cache_hits_per_second", "Number of requests served from the public cache per second.", prometheus.GaugeValue),
+		reqRateTotalPubCacheHitsField:      newReqRateMetric("public_cache_hits_total", "Total number of requests served from the public cache.", prometheus.CounterValue),
+		reqRatePrivateCacheHitsPerSecField: newReqRateMetric("private_cache_hits_per_second", "Number of requests served from the private cache per second.", prometheus.GaugeValue),
+		reqRateTotalPrivateCacheHitsField:  newReqRateMetric("private_cache_hits_total", "Total number of requests served from the private cache.", prometheus.CounterValue),
+		reqRateStaticHitsPerSecField:       newReqRateMetric("static_hits_per_second", "Number of requests for static files per second.", prometheus.GaugeValue),
+		reqRateTotalStaticHitsField:        newReqRateMetric("static_hits_total", "Total number of requests for static files.", prometheus.CounterValue),
+		extappCmaxconnField:                newExtappMetric("extapp_configured_max_connections", "Maximum number of connections configured for the external application.", prometheus.GaugeValue),
+		extappEmaxconnField:                newExtappMetric("extapp_effective_max_connections", "Maximum number of connections the external application can actually use.", prometheus.GaugeValue),
+		extappPoolSizeField:                newExtappMetric("extapp_pool_size", "Number of external application processes in the pool.", prometheus.GaugeValue),
+		extappInuseConnField:               newExtappMetric("extapp_connections", "Number of connections to the external application, by state.", prometheus.GaugeValue).withConstLabels(prometheus.Labels{"state": "in_use"}),
+		extappIdleConnField:                newExtappMetric("extapp_connections", "Number of connections to the external application, by state.", prometheus.GaugeValue).withConstLabels(prometheus.Labels{"state": "idle"}),
+		extappWaitqueDepthField:            newExtappMetric("extapp_wait_queue_depth", "Number of requests waiting for a connection to the external application.", prometheus.GaugeValue),
+		extappReqPerSecField:               newExtappMetric("extapp_requests_per_second", "Number of requests handled by the external application per second.", prometheus.GaugeValue),
+		extappTotReqsField:                 newExtappMetric("extapp_requests_total", "Total number of requests handled by the external application.", prometheus.CounterValue),
+		blockedIPField:                     newGenericMetric("blocked_ips", "Number of IP addresses currently blocked by LiteSpeed.", prometheus.GaugeValue),
 	}
-	litespeedVersion = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "version"), "A metric with a constant '1' value labeled by the LiteSpeed version.", []string{"version"}, nil)
-	litespeedUp      = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "up"), "Was the last scrape of LiteSpeed successful.", nil, nil)
+
+	// legacyMetrics holds the names fields were exported with before, kept
+	// with --compat.legacy-metric-names while dashboards are migrated
+	legacyMetrics = newLegacyMetrics(
+		bpsInField, bpsOutField, sslBpsInField, sslBpsOutField,
+		maxconnField, maxsslConnField, plainconnField, availconnField, idleconnField, sslconnField, availsslField,
+		reqRateReqProcessingField, reqRateReqPerSecField, reqRateTotReqsField,
+		reqRatePubCacheHitsPerSecField, reqRateTotalPubCacheHitsField,
+		reqRatePrivateCacheHitsPerSecField, reqRateTotalPrivateCacheHitsField,
+		reqRateStaticHitsPerSecField, reqRateTotalStaticHitsField,
+		extappCmaxconnField, extappEmaxconnField, extappInuseConnField,
+		extappIdleConnField, extappWaitqueDepthField, extappReqPerSecField, extappTotReqsField,
+	)
+
+	litespeedVersion   = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "version"), "A metric with a constant '1' value labeled by the LiteSpeed version.", []string{"version"}, nil)
+	litespeedUp        = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "up"), "Was the last scrape of LiteSpeed successful.", nil, nil)
+	litespeedUptime    = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "uptime_seconds"), "Number of seconds since the LiteSpeed server was started.", []string{"core"}, nil)
+	litespeedBuildInfo = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "build_info"), "A metric with a constant '1' value labeled by the product, edition and version of LiteSpeed.", []string{"product", "edition", "version", "major", "minor"}, nil)
+	litespeedMismatch  = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "version_mismatch"), "Whether the scraped reports show different LiteSpeed versions, as during a graceful upgrade.", nil, nil)
+	litespeedBlockedIP = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "blocked_ip_top"), "Number of blocked entries for the most blocked IP addresses or prefixes.", []string{"core", "address"}, nil)
+	litespeedReportAge = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "report_age_seconds"), "Number of seconds since the report was last written by LiteSpeed.", []string{"core"}, nil)
+	litespeedStale     = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "report_stale"), "Whether the report is older than the staleness threshold and left out of the exported metrics.", []string{"core"}, nil)
+
+	// Aggregates of the external applications run by every user, see handler_users.go
+	litespeedUserExtappConnections = prometheus.NewDesc(prometheus.BuildFQName(namespace, "user", "extapp_connections"), "Number of connections to the external applications of the user, by state.", []string{"core", "user", "state"}, nil)
+	litespeedUserExtappWaitQueue   = prometheus.NewDesc(prometheus.BuildFQName(namespace, "user", "extapp_wait_queue_depth"), "Number of requests waiting for a connection to the external applications of the user.", []string{"core", "user"}, nil)
+	litespeedUserExtappRequests    = prometheus.NewDesc(prometheus.BuildFQName(namespace, "user", "extapp_requests_total"), "Total number of requests handled by the external applications of the user.", []string{"core", "user"}, nil)
+
+	// Ratios derived from the report fields, see ratios.go
+	litespeedConnectionUtilization = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "connection_utilization_ratio"), "Ratio of open connections to the maximum number of connections, by connection type.", []string{"core", "type"}, nil)
+	litespeedCacheHitRatio         = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "cache_hit_ratio"), "Ratio of requests served from the public or private cache or as static files to all requests served.", []string{"core", "hostname"}, nil)
+	litespeedExtappUtilization     = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "extapp_utilization_ratio"), "Ratio of connections in use to the configured maximum number of connections of the external application.", []string{"core", "service", "hostname", "handler"}, nil)
+	litespeedExtappQueued          = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "extapp_queue_active"), "Whether requests are waiting for a connection to the external application.", []string{"core", "service", "hostname", "handler"}, nil)
 )
 
+// builtinDescs are the metrics exported besides the ones of the fields,
+// which the metric mappings can't clash with
+var builtinDescs = []*prometheus.Desc{
+	litespeedVersion, litespeedUp, litespeedUptime, litespeedBuildInfo, litespeedMismatch,
+	litespeedBlockedIP, litespeedReportAge, litespeedStale,
+	litespeedUserExtappConnections, litespeedUserExtappWaitQueue, litespeedUserExtappRequests,
+	litespeedConnectionUtilization, litespeedCacheHitRatio, litespeedExtappUtilization, litespeedExtappQueued,
+}
+
 type metricInfo struct {
 	Desc *prometheus.Desc
 	Type prometheus.ValueType
+	// Scale converts the field value to the base unit of the metric, values are exported as is when 0
+	Scale float64
+
+	name, help     string
+	variableLabels []string
+	constLabels    prometheus.Labels
+}
+
+// withConstLabels returns a copy of the metric with the given constant
+// labels, telling apart the fields exported under the same name
+func (m metricInfo) withConstLabels(labels prometheus.Labels) metricInfo {
+	m.constLabels = labels
+	m.Desc = prometheus.NewDesc(m.name, m.help, m.variableLabels, labels)
+	return m
+}
+
+// withVariableLabels returns a copy of the metric with the given variable
+// labels appended to its own
+func (m metricInfo) withVariableLabels(labels ...string) metricInfo {
+	m.variableLabels = append(append([]string{}, m.variableLabels...), labels...)
+	m.Desc = prometheus.NewDesc(m.name, m.help, m.variableLabels, m.constLabels)
+	return m
+}
+
+// withSuffix returns a copy of the metric exported under its name with the
+// given suffix, as a gauge
+func (m metricInfo) withSuffix(suffix, help string) metricInfo {
+	m.name += suffix
+	m.help = help
+	m.Type = prometheus.GaugeValue
+	m.Desc = prometheus.NewDesc(m.name, m.help, m.variableLabels, m.constLabels)
+	return m
+}
+
+// scaledBy returns a copy of the metric whose values are multiplied by scale
+func (m metricInfo) scaledBy(scale float64) metricInfo {
+	m.Scale = scale
+	return m
+}
+
+// value converts a field value to the unit of the metric
+func (m metricInfo) value(v float64) float64 {
+	if m.Scale == 0 {
+		return v
+	}
+	return v * m.Scale
 }
 
 type metrics map[string]metricInfo
@@ -65,38 +153,43 @@ func (m metrics) String() string {
 	return strings.Join(s, ", ")
 }
 
-func newGenericMetric(metricName string, docString string, t prometheus.ValueType) metricInfo {
+func newMetric(metricName string, docString string, t prometheus.ValueType, variableLabels []string) metricInfo {
+	name := prometheus.BuildFQName(namespace, "", strings.ToLower(metricName))
 	return metricInfo{
-		Desc: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", strings.ToLower(metricName)),
-			docString,
-			[]string{"core"},
-			nil,
-		),
-		Type: t,
+		Desc:           prometheus.NewDesc(name, docString, variableLabels, nil),
+		Type:           t,
+		name:           name,
+		help:           docString,
+		variableLabels: variableLabels,
 	}
 }
 
+func newGenericMetric(metricName string, docString string, t prometheus.ValueType) metricInfo {
+	return newMetric(metricName, docString, t, []string{"core"})
+}
+
 func newReqRateMetric(metricName string, docString string, t prometheus.ValueType) metricInfo {
-	return metricInfo{
-		Desc: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", strings.ToLower(metricName)),
-			docString,
-			[]string{"core", "hostname"},
-			nil,
-		),
-		Type: t,
-	}
+	return newMetric(metricName, docString, t, []string{"core", "hostname"})
 }
 
 func newExtappMetric(metricName string, docString string, t prometheus.ValueType) metricInfo {
-	return metricInfo{
-		Desc: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", strings.ToLower(metricName)),
-			docString,
-			[]string{"core", "service", "hostname", "handler"},
-			nil,
-		),
-		Type: t,
+	return newMetric(metricName, docString, t, []string{"core", "service", "hostname", "handler"})
+}
+
+// newLegacyMetrics describes the given fields the way releases before the
+// metric names were normalised did
+func newLegacyMetrics(fields ...string) metrics {
+	m := make(metrics, len(fields))
+	for _, field := range fields {
+		help := field + " metric."
+		switch fieldLabelSet(field) {
+		case reqRateLabelSet:
+			m[field] = newReqRateMetric(field, help, prometheus.GaugeValue)
+		case extappLabelSet:
+			m[field] = newExtappMetric(field, help, prometheus.GaugeValue)
+		default:
+			m[field] = newGenericMetric(field, help, prometheus.GaugeValue)
+		}
 	}
+	return m
 }
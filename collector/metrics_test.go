@@ -9,7 +9,7 @@ import (
 
 func TestStringReturnsAvailableMetricsString(t *testing.T) {
 	s := LitespeedMetrics.String()
-	e := "AVAILCONN, AVAILSSL, BPS_IN, BPS_OUT, EXTAPP_CMAXCONN, EXTAPP_EMAXCONN, EXTAPP_IDLE_CONN, EXTAPP_INUSE_CONN, EXTAPP_POOL_SIZE, EXTAPP_REQ_PER_SEC, EXTAPP_TOT_REQS, EXTAPP_WAITQUE_DEPTH, IDLECONN, MAXCONN, MAXSSL_CONN, PLAINCONN, REQ_RATE_PRIVATE_CACHE_HITS_PER_SEC, REQ_RATE_PUB_CACHE_HITS_PER_SEC, REQ_RATE_REQ_PER_SEC, REQ_RATE_REQ_PROCESSING, REQ_RATE_STATIC_HITS_PER_SEC, REQ_RATE_TOTAL_PRIVATE_CACHE_HITS, REQ_RATE_TOTAL_PUB_CACHE_HITS, REQ_RATE_TOTAL_STATIC_HITS, REQ_RATE_TOT_REQS, SSLCONN, SSL_BPS_IN, SSL_BPS_OUT"
+	e := "AVAILCONN, AVAILSSL, BLOCKED_IP, BPS_IN, BPS_OUT, EXTAPP_CMAXCONN, EXTAPP_EMAXCONN, EXTAPP_IDLE_CONN, EXTAPP_INUSE_CONN, EXTAPP_POOL_SIZE, EXTAPP_REQ_PER_SEC, EXTAPP_TOT_REQS, EXTAPP_WAITQUE_DEPTH, IDLECONN, MAXCONN, MAXSSL_CONN, PLAINCONN, REQ_RATE_PRIVATE_CACHE_HITS_PER_SEC, REQ_RATE_PUB_CACHE_HITS_PER_SEC, REQ_RATE_REQ_PER_SEC, REQ_RATE_REQ_PROCESSING, REQ_RATE_STATIC_HITS_PER_SEC, REQ_RATE_TOTAL_PRIVATE_CACHE_HITS, REQ_RATE_TOTAL_PUB_CACHE_HITS, REQ_RATE_TOTAL_STATIC_HITS, REQ_RATE_TOT_REQS, SSLCONN, SSL_BPS_IN, SSL_BPS_OUT"
 
 	assert.Equal(t, e, s)
 }
@@ -34,3 +34,11 @@ func TestCreatesNewExtappMetric(t *testing.T) {
 	assert.Equal(t, prometheus.GaugeValue, m.Type)
 	assert.Equal(t, "Desc{fqName: \"litespeed_extapp_cmaxconn\", help: \"test metric\", constLabels: {}, variableLabels: [core service hostname handler]}", m.Desc.String())
 }
+
+func TestLegacyMetricsOnlyRenameKnownMetrics(t *testing.T) {
+	for flag, legacy := range legacyMetrics {
+		metric, ok := LitespeedMetrics[flag]
+		assert.True(t, ok, "Legacy metric %s missing from LitespeedMetrics", flag)
+		assert.NotEqual(t, metric.name, legacy.name, "Legacy metric %s isn't renamed", flag)
+	}
+}
@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPasswdFile is where the system users are listed
+const DefaultPasswdFile = "/etc/passwd"
+
+// parsePasswd returns the user names of a passwd file by UID, skipping the
+// lines that can't be parsed
+func parsePasswd(r io.Reader) (map[string]string, error) {
+	users := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 || fields[0] == "" {
+			continue
+		}
+		if _, err := strconv.ParseUint(fields[2], 10, 32); err != nil {
+			continue
+		}
+		// The first entry wins, as with getpwuid
+		if _, ok := users[fields[2]]; !ok {
+			users[fields[2]] = fields[0]
+		}
+	}
+
+	return users, scanner.Err()
+}
+
+// passwdFile resolves UIDs to user names, reading the passwd file again
+// whenever it changes as users come and go on shared hosting servers
+type passwdFile struct {
+	path    string
+	modTime time.Time
+	users   map[string]string
+}
+
+// lookup returns the name of the user with the given UID, or the UID itself
+// when it's unknown
+func (p *passwdFile) lookup(uid string) string {
+	if name, ok := p.users[uid]; ok {
+		return name
+	}
+	return uid
+}
+
+// refresh reads the passwd file again when it was modified since the last read
+func (p *passwdFile) refresh() error {
+	fi, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+	if p.users != nil && fi.ModTime().Equal(p.modTime) {
+		return nil
+	}
+
+	f, err := os.Open(p.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	users, err := parsePasswd(f)
+	if err != nil {
+		return err
+	}
+
+	p.users, p.modTime = users, fi.ModTime()
+	return nil
+}
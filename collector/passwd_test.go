@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePasswdSkipsMalformedLines(t *testing.T) {
+	f, err := os.Open(path.Join("..", "testdata", "passwd"))
+	assert.NoError(t, err)
+	defer f.Close()
+
+	users, err := parsePasswd(f)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"0": "root", "65534": "nobody", "10000": "alice", "10001": "bob"}, users)
+}
+
+func TestPasswdFileLookupFallsBackToUID(t *testing.T) {
+	p := &passwdFile{path: path.Join("..", "testdata", "passwd")}
+	assert.NoError(t, p.refresh())
+
+	assert.Equal(t, "alice", p.lookup("10000"))
+	assert.Equal(t, "10002", p.lookup("10002"))
+
+	missing := &passwdFile{path: "/nonexistent/passwd"}
+	assert.Error(t, missing.refresh())
+	assert.Equal(t, "10000", missing.lookup("10000"))
+}
@@ -0,0 +1,229 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+)
+
+// ProcessCollectorOpts carries the options used in ProcessCollector
+type ProcessCollectorOpts struct {
+	// PIDFile is the file lshttpd writes its PID to, DefaultPIDFile when empty
+	PIDFile string
+	// ProcfsPath is where procfs is mounted, procfs.DefaultMountPoint when empty
+	ProcfsPath string
+}
+
+// lshttpdProcessName matches the title lshttpd gives to its processes, such
+// as "litespeed (lshttpd - main)" or "openlitespeed (lshttpd - #01)"
+var lshttpdProcessName = regexp.MustCompile(`\(lshttpd - ([^)]+)\)`)
+
+var (
+	processCPU       = prometheus.NewDesc(prometheus.BuildFQName(namespace, "process", "cpu_seconds_total"), "Total user and system CPU time spent by the lshttpd process in seconds.", []string{"process"}, nil)
+	processRSS       = prometheus.NewDesc(prometheus.BuildFQName(namespace, "process", "resident_memory_bytes"), "Resident memory size of the lshttpd process in bytes.", []string{"process"}, nil)
+	processOpenFDs   = prometheus.NewDesc(prometheus.BuildFQName(namespace, "process", "open_fds"), "Number of open file descriptors of the lshttpd process.", []string{"process"}, nil)
+	processMaxFDs    = prometheus.NewDesc(prometheus.BuildFQName(namespace, "process", "max_fds"), "Maximum number of open file descriptors of the lshttpd process.", []string{"process"}, nil)
+	processThreads   = prometheus.NewDesc(prometheus.BuildFQName(namespace, "process", "threads"), "Number of threads of the lshttpd process.", []string{"process"}, nil)
+	processStartTime = prometheus.NewDesc(prometheus.BuildFQName(namespace, "process", "start_time_seconds"), "Start time of the lshttpd process since unix epoch in seconds.", []string{"process"}, nil)
+	processWorkers   = prometheus.NewDesc(prometheus.BuildFQName(namespace, "process", "workers"), "Number of lshttpd worker processes.", nil, nil)
+)
+
+// ProcessCollector exports the resource usage of the main lshttpd process and
+// its workers, read from procfs
+type ProcessCollector struct {
+	options        ProcessCollectorOpts
+	fs             procfs.FS
+	scrapeFailures prometheus.Counter
+	logger         log.Logger
+}
+
+// NewProcessCollector returns constructed collector, failing when procfs
+// can't be found
+func NewProcessCollector(opts ProcessCollectorOpts, logger log.Logger) (*ProcessCollector, error) {
+	if opts.PIDFile == "" {
+		opts.PIDFile = DefaultPIDFile
+	}
+	if opts.ProcfsPath == "" {
+		opts.ProcfsPath = procfs.DefaultMountPoint
+	}
+
+	fs, err := procfs.NewFS(opts.ProcfsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProcessCollector{
+		options: opts,
+		fs:      fs,
+		scrapeFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "process",
+			Name:      "scrape_failures_total",
+			Help:      "Number of errors while reading the lshttpd processes.",
+		}),
+		logger: logger,
+	}, nil
+}
+
+// Describe describes all the metrics that can be exported by the process collector
+func (c *ProcessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- processCPU
+	ch <- processRSS
+	ch <- processOpenFDs
+	ch <- processMaxFDs
+	ch <- processThreads
+	ch <- processStartTime
+	ch <- processWorkers
+	ch <- c.scrapeFailures.Desc()
+}
+
+// Collect reads the lshttpd processes and delivers their usage as Prometheus metrics
+func (c *ProcessCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collectProcesses(ch)
+	ch <- c.scrapeFailures
+}
+
+func (c *ProcessCollector) collectProcesses(ch chan<- prometheus.Metric) {
+	pid, err := readPIDFile(c.options.PIDFile)
+	if err != nil {
+		// LiteSpeed being down is already reported by litespeed_up
+		level.Debug(c.logger).Log("msg", "Can't read PID file", "file", c.options.PIDFile, "err", err)
+		return
+	}
+
+	main, err := c.fs.Proc(pid)
+	if err != nil {
+		level.Debug(c.logger).Log("msg", "Can't find lshttpd process", "pid", pid, "err", err)
+		return
+	}
+	if !c.collectProcess("main", main, ch) {
+		return
+	}
+
+	workers, err := c.workers(pid)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "Can't list lshttpd workers", "err", err)
+		c.scrapeFailures.Inc()
+		return
+	}
+
+	for name, proc := range workers {
+		c.collectProcess(name, proc, ch)
+	}
+	ch <- prometheus.MustNewConstMetric(processWorkers, prometheus.GaugeValue, float64(len(workers)))
+}
+
+// workers returns the lshttpd children of the main process by name, leaving
+// out the CGI daemon and the external applications
+func (c *ProcessCollector) workers(mainPID int) (map[string]procfs.Proc, error) {
+	pids, err := c.childPIDs(mainPID)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := make(map[string]procfs.Proc)
+	for _, pid := range pids {
+		// Processes can exit while being listed
+		proc, err := c.fs.Proc(pid)
+		if err != nil {
+			continue
+		}
+		cmdline, err := proc.CmdLine()
+		if err != nil {
+			continue
+		}
+		if m := lshttpdProcessName.FindStringSubmatch(strings.Join(cmdline, " ")); m != nil {
+			workers[m[1]] = proc
+		}
+	}
+
+	return workers, nil
+}
+
+// childPIDs returns the PIDs of the children of a process, read from the
+// children files of its threads. The parent of every process is read instead
+// when the kernel doesn't provide them.
+func (c *ProcessCollector) childPIDs(pid int) ([]int, error) {
+	files, err := filepath.Glob(filepath.Join(c.options.ProcfsPath, strconv.Itoa(pid), "task", "*", "children"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return c.childPIDsByParent(pid)
+	}
+
+	var pids []int
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			// Threads can exit while being listed
+			continue
+		}
+		for _, field := range strings.Fields(string(data)) {
+			if child, err := strconv.Atoi(field); err == nil {
+				pids = append(pids, child)
+			}
+		}
+	}
+	return pids, nil
+}
+
+func (c *ProcessCollector) childPIDsByParent(pid int) ([]int, error) {
+	procs, err := c.fs.AllProcs()
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, proc := range procs {
+		if stat, err := proc.Stat(); err == nil && stat.PPID == pid {
+			pids = append(pids, proc.PID)
+		}
+	}
+	return pids, nil
+}
+
+// collectProcess exports the usage of a single process, telling whether it
+// could be read
+func (c *ProcessCollector) collectProcess(name string, proc procfs.Proc, ch chan<- prometheus.Metric) bool {
+	stat, err := proc.Stat()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			level.Error(c.logger).Log("msg", "Can't read process stats", "process", name, "pid", proc.PID, "err", err)
+			c.scrapeFailures.Inc()
+		}
+		return false
+	}
+
+	ch <- prometheus.MustNewConstMetric(processCPU, prometheus.CounterValue, stat.CPUTime(), name)
+	ch <- prometheus.MustNewConstMetric(processRSS, prometheus.GaugeValue, float64(stat.ResidentMemory()), name)
+	ch <- prometheus.MustNewConstMetric(processThreads, prometheus.GaugeValue, float64(stat.NumThreads), name)
+	if startTime, err := stat.StartTime(); err == nil {
+		ch <- prometheus.MustNewConstMetric(processStartTime, prometheus.GaugeValue, startTime, name)
+	} else {
+		level.Debug(c.logger).Log("msg", "Can't read process start time", "process", name, "err", err)
+	}
+
+	// The file descriptors of processes run by another user can't be listed
+	// unless the exporter runs as root
+	if fds, err := proc.FileDescriptorsLen(); err == nil {
+		ch <- prometheus.MustNewConstMetric(processOpenFDs, prometheus.GaugeValue, float64(fds), name)
+	} else {
+		level.Debug(c.logger).Log("msg", "Can't read process file descriptors", "process", name, "err", err)
+	}
+	if limits, err := proc.Limits(); err == nil {
+		ch <- prometheus.MustNewConstMetric(processMaxFDs, prometheus.GaugeValue, float64(limits.OpenFiles), name)
+	} else {
+		level.Debug(c.logger).Log("msg", "Can't read process limits", "process", name, "err", err)
+	}
+
+	return true
+}
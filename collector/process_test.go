@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessCollectorExportsMainAndWorkers(t *testing.T) {
+	c, err := NewProcessCollector(
+		ProcessCollectorOpts{
+			PIDFile:    path.Join("..", "testdata", "lshttpd.pid"),
+			ProcfsPath: path.Join("..", "testdata", "proc"),
+		},
+		log.NewNopLogger(),
+	)
+	assert.NoError(t, err)
+
+	// Resident memory is reported in pages
+	pageSize := os.Getpagesize()
+	expected := fmt.Sprintf(`
+# HELP litespeed_process_cpu_seconds_total Total user and system CPU time spent by the lshttpd process in seconds.
+# TYPE litespeed_process_cpu_seconds_total counter
+litespeed_process_cpu_seconds_total{process="#01"} 400
+litespeed_process_cpu_seconds_total{process="#02"} 300
+litespeed_process_cpu_seconds_total{process="main"} 2
+# HELP litespeed_process_max_fds Maximum number of open file descriptors of the lshttpd process.
+# TYPE litespeed_process_max_fds gauge
+litespeed_process_max_fds{process="#01"} 65536
+litespeed_process_max_fds{process="#02"} 65536
+litespeed_process_max_fds{process="main"} 4096
+# HELP litespeed_process_open_fds Number of open file descriptors of the lshttpd process.
+# TYPE litespeed_process_open_fds gauge
+litespeed_process_open_fds{process="#01"} 12
+litespeed_process_open_fds{process="#02"} 10
+litespeed_process_open_fds{process="main"} 4
+# HELP litespeed_process_resident_memory_bytes Resident memory size of the lshttpd process in bytes.
+# TYPE litespeed_process_resident_memory_bytes gauge
+litespeed_process_resident_memory_bytes{process="#01"} %d
+litespeed_process_resident_memory_bytes{process="#02"} %d
+litespeed_process_resident_memory_bytes{process="main"} %d
+# HELP litespeed_process_scrape_failures_total Number of errors while reading the lshttpd processes.
+# TYPE litespeed_process_scrape_failures_total counter
+litespeed_process_scrape_failures_total 0
+# HELP litespeed_process_start_time_seconds Start time of the lshttpd process since unix epoch in seconds.
+# TYPE litespeed_process_start_time_seconds gauge
+litespeed_process_start_time_seconds{process="#01"} 1.600000011e+09
+litespeed_process_start_time_seconds{process="#02"} 1.600000011e+09
+litespeed_process_start_time_seconds{process="main"} 1.60000001e+09
+# HELP litespeed_process_threads Number of threads of the lshttpd process.
+# TYPE litespeed_process_threads gauge
+litespeed_process_threads{process="#01"} 8
+litespeed_process_threads{process="#02"} 8
+litespeed_process_threads{process="main"} 1
+# HELP litespeed_process_workers Number of lshttpd worker processes.
+# TYPE litespeed_process_workers gauge
+litespeed_process_workers 2
+`, 51200*pageSize, 40960*pageSize, 2048*pageSize)
+
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+}
+
+func TestProcessCollectorHandlesMissingProcess(t *testing.T) {
+	f, _ := ioutil.TempFile("", "TestProcessCollectorHandlesMissingProcess")
+	defer os.Remove(f.Name())
+	f.Write([]byte("999"))
+
+	tests := []string{
+		"/tmp/TestProcessCollectorHandlesMissingProcess.pid",
+		f.Name(),
+	}
+
+	for _, pidFile := range tests {
+		c, err := NewProcessCollector(
+			ProcessCollectorOpts{
+				PIDFile:    pidFile,
+				ProcfsPath: path.Join("..", "testdata", "proc"),
+			},
+			log.NewNopLogger(),
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, testutil.CollectAndCount(c))
+		assert.Equal(t, 0.0, testutil.ToFloat64(c.scrapeFailures))
+	}
+}
+
+func TestNewProcessCollectorFailsWithoutProcfs(t *testing.T) {
+	_, err := NewProcessCollector(ProcessCollectorOpts{ProcfsPath: "/nonexistent"}, log.NewNopLogger())
+	assert.Error(t, err)
+}
+
+func TestChildPIDs(t *testing.T) {
+	c, err := NewProcessCollector(ProcessCollectorOpts{ProcfsPath: path.Join("..", "testdata", "proc")}, log.NewNopLogger())
+	assert.NoError(t, err)
+
+	// Read from the children files of the threads of the process...
+	pids, err := c.childPIDs(1000)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int{1001, 1002, 1003}, pids)
+
+	// ...or from the parent of every process without them
+	pids, err = c.childPIDs(1004)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2001}, pids)
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"github.com/hostinger/litespeed_exporter/rtreport"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ratio divides the values of two fields, failing when either is missing or
+// the denominator is zero
+func ratio(kv map[string]float64, numerator, denominator string) (float64, bool) {
+	n, ok := kv[numerator]
+	if !ok {
+		return 0, false
+	}
+	d, ok := kv[denominator]
+	if !ok || d == 0 {
+		return 0, false
+	}
+	return n / d, true
+}
+
+// cacheHitRatio returns the share of requests served from a cache or as
+// static files
+func cacheHitRatio(kv map[string]float64) (float64, bool) {
+	total, ok := kv[reqRateTotReqsField]
+	if !ok || total == 0 {
+		return 0, false
+	}
+
+	hits, found := 0.0, false
+	for _, flag := range []string{reqRateTotalPubCacheHitsField, reqRateTotalPrivateCacheHitsField, reqRateTotalStaticHitsField} {
+		if v, ok := kv[flag]; ok {
+			hits += v
+			found = true
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	return hits / total, true
+}
+
+// collectRatioMetrics exports the ratios derived from a report. Ratios whose
+// fields are missing or excluded are skipped.
+func (c *LitespeedCollector) collectRatioMetrics(core string, report rtreport.Report, ch chan<- prometheus.Metric) {
+	if v, ok := ratio(report.GeneralInfo.KeyValues, plainconnField, maxconnField); ok {
+		ch <- prometheus.MustNewConstMetric(litespeedConnectionUtilization, prometheus.GaugeValue, v, core, "plain")
+	}
+	if v, ok := ratio(report.GeneralInfo.KeyValues, sslconnField, maxsslConnField); ok {
+		ch <- prometheus.MustNewConstMetric(litespeedConnectionUtilization, prometheus.GaugeValue, v, core, "ssl")
+	}
+
+	for _, rrReport := range report.ReqRates {
+		if v, ok := cacheHitRatio(rrReport.KeyValues); ok {
+			ch <- prometheus.MustNewConstMetric(litespeedCacheHitRatio, prometheus.GaugeValue, v, core, rrReport.Hostname)
+		}
+	}
+
+	for _, eaReport := range report.ExtApps {
+		if v, ok := ratio(eaReport.KeyValues, extappInuseConnField, extappCmaxconnField); ok {
+			ch <- prometheus.MustNewConstMetric(litespeedExtappUtilization, prometheus.GaugeValue, v, core, eaReport.Service, eaReport.Hostname, eaReport.Handler)
+		}
+		if depth, ok := eaReport.KeyValues[extappWaitqueDepthField]; ok {
+			queued := 0.0
+			if depth > 0 {
+				queued = 1
+			}
+			ch <- prometheus.MustNewConstMetric(litespeedExtappQueued, prometheus.GaugeValue, queued, core, eaReport.Service, eaReport.Hostname, eaReport.Handler)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRatioReturnsExpected(t *testing.T) {
+	tests := []struct {
+		kv map[string]float64
+		e  float64
+		ok bool
+	}{
+		{map[string]float64{plainconnField: 55, maxconnField: 10000}, 0.0055, true},
+		{map[string]float64{plainconnField: 55, maxconnField: 0}, 0, false},
+		{map[string]float64{plainconnField: 55}, 0, false},
+		{map[string]float64{maxconnField: 10000}, 0, false},
+	}
+
+	for _, tc := range tests {
+		v, ok := ratio(tc.kv, plainconnField, maxconnField)
+		assert.Equal(t, tc.ok, ok)
+		assert.Equal(t, tc.e, v)
+	}
+}
+
+func TestCacheHitRatioReturnsExpected(t *testing.T) {
+	tests := []struct {
+		kv map[string]float64
+		e  float64
+		ok bool
+	}{
+		{map[string]float64{reqRateTotReqsField: 100, reqRateTotalPubCacheHitsField: 20, reqRateTotalPrivateCacheHitsField: 5, reqRateTotalStaticHitsField: 25}, 0.5, true},
+		{map[string]float64{reqRateTotReqsField: 100, reqRateTotalStaticHitsField: 10}, 0.1, true},
+		{map[string]float64{reqRateTotReqsField: 0, reqRateTotalStaticHitsField: 10}, 0, false},
+		{map[string]float64{reqRateTotReqsField: 100}, 0, false},
+		{map[string]float64{reqRateTotalStaticHitsField: 10}, 0, false},
+	}
+
+	for _, tc := range tests {
+		v, ok := cacheHitRatio(tc.kv)
+		assert.Equal(t, tc.ok, ok)
+		assert.Equal(t, tc.e, v)
+	}
+}
@@ -1,93 +1,58 @@
 package collector
 
-type generalInfoReport struct {
-	Version   string
-	Uptime    string
-	KeyValues map[string]float64
-}
-
-type requestRateReport struct {
-	Hostname  string
-	KeyValues map[string]float64
-}
-
-type externalAppReport struct {
-	Service   string
-	Hostname  string
-	Handler   string
-	KeyValues map[string]float64
-}
-
-type litespeedReport struct {
-	GeneralInfo generalInfoReport
-	ReqRates    []requestRateReport
-	ExtApps     []externalAppReport
-}
-
-func (lr *litespeedReport) indexOfReqRate(f func(r requestRateReport) bool) int {
-	for i, rrReport := range lr.ReqRates {
-		if f(rrReport) {
-			return i
-		}
+import (
+	"sort"
+
+	"github.com/hostinger/litespeed_exporter/rtreport"
+)
+
+func sumReports(reports map[string]rtreport.Report) *rtreport.Report {
+	// Add reports in file name order so that the last-wins fields, such as
+	// the version and uptime, don't depend on map iteration order.
+	names := make([]string, 0, len(reports))
+	for name := range reports {
+		names = append(names, name)
 	}
-	return -1
-}
+	sort.Strings(names)
 
-func (lr *litespeedReport) indexOfExtApp(f func(r externalAppReport) bool) int {
-	for i, eaReport := range lr.ExtApps {
-		if f(eaReport) {
-			return i
-		}
+	sorted := make([]rtreport.Report, 0, len(names))
+	for _, name := range names {
+		sorted = append(sorted, reports[name])
 	}
-	return -1
-}
 
-func (lr *litespeedReport) Add(b litespeedReport) {
-	lr.GeneralInfo.Version = b.GeneralInfo.Version
-	lr.GeneralInfo.Uptime = b.GeneralInfo.Uptime
-	for flag, value := range b.GeneralInfo.KeyValues {
-		sumOrAppend(lr.GeneralInfo.KeyValues, flag, value)
-	}
-
-	for _, rrReport := range b.ReqRates {
-		i := lr.indexOfReqRate(func(r requestRateReport) bool {
-			return r.Hostname == rrReport.Hostname
-		})
+	return rtreport.Merge(sorted...)
+}
 
-		if i > -1 {
-			for k, v := range rrReport.KeyValues {
-				sumOrAppend(lr.ReqRates[i].KeyValues, k, v)
+// reportHandlers returns the sorted names of the external application
+// handlers found in the reports
+func reportHandlers(reports map[string]rtreport.Report) []string {
+	seen := make(map[string]bool)
+	handlers := []string{}
+	for _, report := range reports {
+		for _, eaReport := range report.ExtApps {
+			if eaReport.Handler != "" && !seen[eaReport.Handler] {
+				seen[eaReport.Handler] = true
+				handlers = append(handlers, eaReport.Handler)
 			}
-		} else {
-			lr.ReqRates = append(lr.ReqRates, rrReport)
 		}
 	}
-
-	for _, eaReport := range b.ExtApps {
-		i := lr.indexOfExtApp(func(r externalAppReport) bool {
-			return r.Service == eaReport.Service && r.Hostname == eaReport.Hostname && r.Handler == eaReport.Handler
-		})
-
-		if i > -1 {
-			for k, v := range eaReport.KeyValues {
-				sumOrAppend(lr.ExtApps[i].KeyValues, k, v)
+	sort.Strings(handlers)
+	return handlers
+}
+
+// reportHostnames returns the sorted names of the virtual hosts found in the
+// REQ_RATE lines of the reports
+func reportHostnames(reports map[string]rtreport.Report) []string {
+	seen := make(map[string]bool)
+	hostnames := []string{}
+	for _, report := range reports {
+		for _, rrReport := range report.ReqRates {
+			if rrReport.Hostname != "" && !seen[rrReport.Hostname] {
+				seen[rrReport.Hostname] = true
+				hostnames = append(hostnames, rrReport.Hostname)
 			}
-		} else {
-			lr.ExtApps = append(lr.ExtApps, eaReport)
 		}
 	}
-}
-
-func sumReports(reports map[string]litespeedReport) *litespeedReport {
-	report := &litespeedReport{
-		GeneralInfo: generalInfoReport{KeyValues: make(map[string]float64)},
-		ReqRates:    []requestRateReport{},
-		ExtApps:     []externalAppReport{},
-	}
-
-	for _, r := range reports {
-		report.Add(r)
-	}
-
-	return report
+	sort.Strings(hostnames)
+	return hostnames
 }
@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/hostinger/litespeed_exporter/rtreport"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sampledMetric describes the series exported for a sampled field
+type sampledMetric struct {
+	min, max, avg, last metricInfo
+}
+
+func newSampledMetric(m metricInfo) sampledMetric {
+	return sampledMetric{
+		min:  m.withSuffix("_min_over_interval", m.help+" Lowest value sampled since the previous scrape."),
+		max:  m.withSuffix("_max_over_interval", m.help+" Highest value sampled since the previous scrape."),
+		avg:  m.withSuffix("_avg_over_interval", m.help+" Average of the values sampled since the previous scrape."),
+		last: m.withSuffix("_last_over_interval", m.help+" Last value sampled since the previous scrape."),
+	}
+}
+
+// newSampledMetrics describes the series exported for the given fields,
+// counters and unknown fields being left out
+func newSampledMetrics(fields map[string]bool) (metrics map[string]sampledMetric, ignored []string) {
+	metrics = make(map[string]sampledMetric)
+	for field := range fields {
+		metric, ok := LitespeedMetrics[field]
+		if !ok || metric.Type != prometheus.GaugeValue {
+			ignored = append(ignored, field)
+			continue
+		}
+		metrics[field] = newSampledMetric(metric)
+	}
+	return metrics, ignored
+}
+
+// sampleKey identifies the series a sample belongs to
+type sampleKey struct {
+	flag, labels string
+}
+
+// sampleStats summarises the values of a series sampled since the previous scrape
+type sampleStats struct {
+	labelValues         []string
+	min, max, sum, last float64
+	count               int
+}
+
+func (s *sampleStats) add(v float64) {
+	if s.count == 0 || v < s.min {
+		s.min = v
+	}
+	if s.count == 0 || v > s.max {
+		s.max = v
+	}
+	s.sum += v
+	s.last = v
+	s.count++
+}
+
+// RunSampler parses the reports every SampleInterval until done is closed,
+// recording the highs and lows the scrapes would miss
+func (c *LitespeedCollector) RunSampler(done <-chan struct{}) {
+	if c.options.SampleInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.options.SampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			c.sample()
+		}
+	}
+}
+
+// sample parses the reports and adds the values of the sampled fields to the
+// current interval, starting a new one when the previous was scraped. The
+// reports are read without holding the lock, so that the scrapes don't wait
+// for the samples, and the exporter metrics only account for the scrapes.
+func (c *LitespeedCollector) sample() {
+	reports, err := c.scrapeReports(newScrapeStats())
+	if err != nil {
+		level.Error(c.logger).Log("msg", "Can't sample reports", "err", err)
+		return
+	}
+	c.dropStaleReports(reports)
+	if !c.options.MetricsByCore {
+		reports = map[string]rtreport.Report{"": *sumReports(reports)}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// The rankings are only updated by the scrapes, the samples following them
+	if c.options.TopHosts > 0 {
+		c.foldHosts(reports)
+	}
+
+	if c.intervalScraped {
+		c.samples = make(map[sampleKey]*sampleStats)
+		c.intervalScraped = false
+	}
+
+	for core, report := range reports {
+		c.recordSamples(report.GeneralInfo.KeyValues, core)
+		for _, rrReport := range report.ReqRates {
+			c.recordSamples(rrReport.KeyValues, core, rrReport.Hostname)
+		}
+		for _, eaReport := range report.ExtApps {
+			c.recordSamples(eaReport.KeyValues, core, eaReport.Service, eaReport.Hostname, eaReport.Handler)
+		}
+	}
+}
+
+// dropStaleReports leaves out the reports older than StaleAfter
+func (c *LitespeedCollector) dropStaleReports(reports map[string]rtreport.Report) {
+	if c.options.StaleAfter <= 0 {
+		return
+	}
+	for core := range reports {
+		if modTime, ok := c.source.modTime(core); ok && time.Since(modTime) > c.options.StaleAfter {
+			delete(reports, core)
+		}
+	}
+}
+
+func (c *LitespeedCollector) recordSamples(kv map[string]float64, labelValues ...string) {
+	for flag, value := range kv {
+		metric, ok := c.sampledMetrics[flag]
+		if !ok {
+			continue
+		}
+
+		key := sampleKey{flag: flag, labels: strings.Join(labelValues, "\xff")}
+		stats, ok := c.samples[key]
+		if !ok {
+			stats = &sampleStats{labelValues: labelValues}
+			c.samples[key] = stats
+		}
+		stats.add(metric.last.value(value))
+	}
+}
+
+// collectSampledMetrics exports the values sampled since the previous scrape,
+// which are exported again until a new sample is taken
+func (c *LitespeedCollector) collectSampledMetrics(ch chan<- prometheus.Metric) {
+	for key, stats := range c.samples {
+		metric := c.sampledMetrics[key.flag]
+		ch <- prometheus.MustNewConstMetric(metric.min.Desc, prometheus.GaugeValue, stats.min, stats.labelValues...)
+		ch <- prometheus.MustNewConstMetric(metric.max.Desc, prometheus.GaugeValue, stats.max, stats.labelValues...)
+		ch <- prometheus.MustNewConstMetric(metric.avg.Desc, prometheus.GaugeValue, stats.sum/float64(stats.count), stats.labelValues...)
+		ch <- prometheus.MustNewConstMetric(metric.last.Desc, prometheus.GaugeValue, stats.last, stats.labelValues...)
+	}
+	c.intervalScraped = true
+}
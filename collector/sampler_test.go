@@ -0,0 +1,205 @@
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSampledMetricsIgnoresCountersAndUnknownFields(t *testing.T) {
+	m, ignored := newSampledMetrics(ParseFlagsToMap([]string{plainconnField, reqRateTotReqsField, "UNKNOWN_FIELD"}))
+
+	assert.Len(t, m, 1)
+	assert.Contains(t, m, plainconnField)
+	assert.ElementsMatch(t, []string{reqRateTotReqsField, "UNKNOWN_FIELD"}, ignored)
+}
+
+func TestSamplerExportsValuesBetweenScrapes(t *testing.T) {
+	f, _ := ioutil.TempFile("", "TestSamplerExportsValuesBetweenScrapes")
+	defer os.Remove(f.Name())
+
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     f.Name(),
+			ReqRatesByHost:  false,
+			MetricsByCore:   false,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			SampleInterval:  time.Second,
+			SampledMetrics:  ParseFlagsToMap([]string{reqRateReqProcessingField, reqRateTotReqsField}),
+		},
+		log.NewNopLogger(),
+	)
+
+	sample := func(processing ...int) {
+		for _, p := range processing {
+			ioutil.WriteFile(f.Name(), []byte(fmt.Sprintf("VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, BPS_OUT: 2\nREQ_RATE []: REQ_PROCESSING: %d, TOT_REQS: 10\nEOF\n", p)), 0644)
+			c.sample()
+		}
+	}
+	expected := func(min, max, avg, last float64) string {
+		return fmt.Sprintf(`
+# HELP litespeed_requests_in_progress_avg_over_interval Number of requests being processed. Average of the values sampled since the previous scrape.
+# TYPE litespeed_requests_in_progress_avg_over_interval gauge
+litespeed_requests_in_progress_avg_over_interval{core="",hostname=""} %v
+# HELP litespeed_requests_in_progress_last_over_interval Number of requests being processed. Last value sampled since the previous scrape.
+# TYPE litespeed_requests_in_progress_last_over_interval gauge
+litespeed_requests_in_progress_last_over_interval{core="",hostname=""} %v
+# HELP litespeed_requests_in_progress_max_over_interval Number of requests being processed. Highest value sampled since the previous scrape.
+# TYPE litespeed_requests_in_progress_max_over_interval gauge
+litespeed_requests_in_progress_max_over_interval{core="",hostname=""} %v
+# HELP litespeed_requests_in_progress_min_over_interval Number of requests being processed. Lowest value sampled since the previous scrape.
+# TYPE litespeed_requests_in_progress_min_over_interval gauge
+litespeed_requests_in_progress_min_over_interval{core="",hostname=""} %v
+`, avg, last, max, min)
+	}
+	names := []string{
+		"litespeed_requests_in_progress_avg_over_interval",
+		"litespeed_requests_in_progress_last_over_interval",
+		"litespeed_requests_in_progress_max_over_interval",
+		"litespeed_requests_in_progress_min_over_interval",
+		"litespeed_requests_total_max_over_interval",
+	}
+
+	sample(2, 8, 5)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected(2, 8, 5, 5)), names...); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+	// Scrapes without new samples export the previous interval again
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected(2, 8, 5, 5)), names...); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+
+	sample(1, 3)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected(1, 3, 2, 3)), names...); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+}
+
+func TestRunSamplerStopsWhenDone(t *testing.T) {
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     path.Join("..", "testdata", ".rtreport"),
+			ReqRatesByHost:  false,
+			MetricsByCore:   false,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			SampleInterval:  time.Millisecond,
+			SampledMetrics:  ParseFlagsToMap([]string{plainconnField}),
+		},
+		log.NewNopLogger(),
+	)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		c.RunSampler(done)
+		close(stopped)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	close(done)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Sampler didn't stop")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	assert.Len(t, c.samples, 1)
+}
+
+func TestSampleLeavesExporterMetricsAlone(t *testing.T) {
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     path.Join("..", "testdata", "malformed_report"),
+			ReqRatesByHost:  false,
+			MetricsByCore:   false,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			SampleInterval:  time.Second,
+			SampledMetrics:  ParseFlagsToMap([]string{plainconnField}),
+		},
+		log.NewNopLogger(),
+	)
+
+	c.sample()
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.scrapeFailures))
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.filesMatched))
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.bytesRead))
+	assert.Equal(t, 0, testutil.CollectAndCount(c.parseErrors))
+	assert.Equal(t, 0, testutil.CollectAndCount(c.phaseDuration))
+}
+
+func TestSampleDoesNotBlockScrapes(t *testing.T) {
+	data, err := ioutil.ReadFile(path.Join("..", "testdata", ".rtreport"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sampling, release := make(chan struct{}), make(chan struct{})
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The request of the sampler hangs and then fails
+		if atomic.AddInt32(&requests, 1) == 1 {
+			close(sampling)
+			<-release
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			MetricsByCore:   true,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			Source:          SourceWebAdmin,
+			WebAdminURL:     server.URL,
+			SampleInterval:  time.Second,
+			SampledMetrics:  ParseFlagsToMap([]string{plainconnField}),
+		},
+		log.NewNopLogger(),
+	)
+	expected := `
+# HELP litespeed_up Was the last scrape of LiteSpeed successful.
+# TYPE litespeed_up gauge
+litespeed_up 1
+`
+
+	sampled := make(chan struct{})
+	go func() {
+		c.sample()
+		close(sampled)
+	}()
+	<-sampling
+
+	scraped := make(chan error)
+	go func() {
+		scraped <- testutil.CollectAndCompare(c, strings.NewReader(expected), "litespeed_up")
+	}()
+	select {
+	case err := <-scraped:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Scrape waited for the sample")
+	}
+
+	close(release)
+	<-sampled
+	assert.Equal(t, 1.0, c.source.up(), "Samples leave litespeed_up alone")
+}
@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Sources the reports can be read from
+const (
+	// SourceFile reads the reports LiteSpeed writes to its runtime directory
+	SourceFile = "file"
+	// SourceWebAdmin fetches the real-time report from the WebAdmin console
+	SourceWebAdmin = "webadmin"
+)
+
+// webAdminTimeout bounds the time spent fetching a report from WebAdmin
+const webAdminTimeout = 10 * time.Second
+
+// reportSource lists and opens the reports to scrape
+type reportSource interface {
+	// names returns the names of the available reports
+	names() ([]string, error)
+	// open opens the report with the given name
+	open(name string) (io.ReadCloser, error)
+	// modTime returns when the report was last written, if known
+	modTime(name string) (time.Time, bool)
+	// up tells whether LiteSpeed is running
+	up() float64
+	// scraped records the last error opening a report during a scrape, the
+	// samples leaving it alone
+	scraped(err error)
+}
+
+// fileSource reads the reports matching a file pattern
+type fileSource struct {
+	pattern string
+	pidFile string
+}
+
+func (s *fileSource) names() ([]string, error) {
+	return filepath.Glob(s.pattern)
+}
+
+func (s *fileSource) open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (s *fileSource) modTime(name string) (time.Time, bool) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return fi.ModTime(), true
+}
+
+func (s *fileSource) up() float64 {
+	return getUpStatus(s.pidFile)
+}
+
+// scraped does nothing, LiteSpeed being up when its main process runs
+func (s *fileSource) scraped(err error) {}
+
+// webAdminSource fetches the real-time report over HTTP(S) with basic auth
+type webAdminSource struct {
+	url                string
+	username, password string
+	client             *http.Client
+	lastErr            error
+}
+
+func newWebAdminSource(url, username, password string, insecure bool) *webAdminSource {
+	return &webAdminSource{
+		url:      url,
+		username: username,
+		password: password,
+		client: &http.Client{
+			Timeout: webAdminTimeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+			},
+		},
+	}
+}
+
+func (s *webAdminSource) names() ([]string, error) {
+	return []string{s.url}, nil
+}
+
+func (s *webAdminSource) open(url string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.username != "" || s.password != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s from WebAdmin", resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// modTime is unknown, WebAdmin generating the report on every request
+func (s *webAdminSource) modTime(name string) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// scraped records whether the report could be fetched during the last scrape
+func (s *webAdminSource) scraped(err error) {
+	s.lastErr = err
+}
+
+// up tells whether the report could be fetched during the last scrape
+func (s *webAdminSource) up() float64 {
+	if s.lastErr != nil {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newWebAdminServer(t *testing.T, report string) *httptest.Server {
+	data, err := ioutil.ReadFile(path.Join("..", "testdata", report))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/status" || r.URL.Query().Get("rpt") != "summary" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}))
+}
+
+func TestWebAdminSourceScrapesReport(t *testing.T) {
+	server := newWebAdminServer(t, ".rtreport")
+	defer server.Close()
+
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			ReqRatesByHost:   true,
+			MetricsByCore:    true,
+			ExcludedMetrics:  ParseFlagsToMap([]string{}),
+			Source:           SourceWebAdmin,
+			WebAdminURL:      server.URL + "/status?rpt=summary",
+			WebAdminUsername: "admin",
+			WebAdminPassword: "secret",
+			WebAdminInsecure: true,
+		},
+		log.NewNopLogger(),
+	)
+	r, err := c.scrapeReports(newScrapeStats())
+
+	assert.Nil(t, err)
+	assert.Len(t, r, 1)
+	report := r[server.URL+"/status?rpt=summary"]
+	assert.Equal(t, "LiteSpeed Web Server/Open/1.6.18", report.GeneralInfo.Version)
+	assert.Equal(t, 5.0, report.GeneralInfo.KeyValues[bpsInField])
+	assert.Len(t, report.ReqRates, 4)
+	assert.Len(t, report.ExtApps, 3)
+	assert.Equal(t, 1.0, c.source.up())
+}
+
+func TestWebAdminSourceHandlesFailures(t *testing.T) {
+	server := newWebAdminServer(t, ".rtreport")
+	defer server.Close()
+
+	tests := []struct {
+		url      string
+		password string
+		insecure bool
+	}{
+		{server.URL + "/status?rpt=summary", "wrong", true},
+		{server.URL + "/status?rpt=detail", "secret", true},
+		{server.URL + "/status?rpt=summary", "secret", false},
+	}
+
+	for _, tc := range tests {
+		c := NewLitespeedCollector(
+			LitespeedCollectorOpts{
+				ExcludedMetrics:  ParseFlagsToMap([]string{}),
+				MetricsByCore:    true,
+				Source:           SourceWebAdmin,
+				WebAdminURL:      tc.url,
+				WebAdminUsername: "admin",
+				WebAdminPassword: tc.password,
+				WebAdminInsecure: tc.insecure,
+			},
+			log.NewNopLogger(),
+		)
+		stats := newScrapeStats()
+		r, err := c.scrapeReports(stats)
+		c.commitScrape(stats)
+
+		assert.Nil(t, err)
+		assert.Len(t, r, 0)
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.scrapeFailures))
+		assert.Equal(t, 0.0, c.source.up())
+	}
+}
@@ -0,0 +1,144 @@
+package collector
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/hostinger/litespeed_exporter/rtreport"
+)
+
+// otherHosts is the hostname the hosts beyond the top ones are folded into
+const otherHosts = "__other__"
+
+// minHostScore is the score below which a host that's gone from the reports is forgotten
+const minHostScore = 1e-9
+
+// hostRanker ranks hosts by an exponentially weighted moving average of a
+// field, so that hosts don't move in and out of the top ones on every scrape
+type hostRanker struct {
+	window     time.Duration
+	scores     map[string]float64
+	lastUpdate time.Time
+}
+
+func newHostRanker(window time.Duration) *hostRanker {
+	return &hostRanker{window: window, scores: make(map[string]float64)}
+}
+
+// update moves the score of every host towards its current value, the hosts
+// missing from values decaying towards zero
+func (r *hostRanker) update(values map[string]float64, now time.Time) {
+	alpha := 1.0
+	if !r.lastUpdate.IsZero() && r.window > 0 {
+		alpha = 1 - math.Exp(-now.Sub(r.lastUpdate).Seconds()/r.window.Seconds())
+	}
+	r.lastUpdate = now
+
+	for host, score := range r.scores {
+		if _, ok := values[host]; ok {
+			continue
+		}
+		score -= alpha * score
+		if score < minHostScore {
+			delete(r.scores, host)
+			continue
+		}
+		r.scores[host] = score
+	}
+	for host, value := range values {
+		score, ok := r.scores[host]
+		if !ok {
+			// New hosts start from their current value
+			r.scores[host] = value
+			continue
+		}
+		r.scores[host] = score + alpha*(value-score)
+	}
+}
+
+// top returns the n hosts with the highest scores, ties being broken by name
+func (r *hostRanker) top(n int) map[string]bool {
+	hosts := make([]string, 0, len(r.scores))
+	for host := range r.scores {
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if r.scores[hosts[i]] != r.scores[hosts[j]] {
+			return r.scores[hosts[i]] > r.scores[hosts[j]]
+		}
+		return hosts[i] < hosts[j]
+	})
+	if len(hosts) > n {
+		hosts = hosts[:n]
+	}
+
+	top := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		top[host] = true
+	}
+	return top
+}
+
+// rankHosts updates the rankings with the reports about to be exported
+func (c *LitespeedCollector) rankHosts(reports map[string]rtreport.Report) {
+	reqRateField := reqRateField + "_" + c.options.TopHostsBy
+	extappField := extappField + "_" + c.options.TopHostsBy
+
+	reqRates, extApps := make(map[string]float64), make(map[string]float64)
+	for _, report := range reports {
+		for _, rrReport := range report.ReqRates {
+			if rrReport.Hostname != "" {
+				reqRates[rrReport.Hostname] += rrReport.KeyValues[reqRateField]
+			}
+		}
+		for _, eaReport := range report.ExtApps {
+			if eaReport.Hostname != "" {
+				extApps[eaReport.Hostname] += eaReport.KeyValues[extappField]
+			}
+		}
+	}
+
+	now := time.Now()
+	c.reqRateHosts.update(reqRates, now)
+	c.extAppHosts.update(extApps, now)
+	c.topReqRates = c.reqRateHosts.top(c.options.TopHosts)
+	c.topExtApps = c.extAppHosts.top(c.options.TopHosts)
+}
+
+// foldedHost returns the hostname and handler a line of the given section is
+// exported with, the hosts beyond the top ones being folded into __other__.
+// The lines without hostname, which are server-wide, are kept.
+func (c *LitespeedCollector) foldedHost(section, hostname, handler string) (string, string) {
+	switch {
+	case hostname == "":
+	case section == "req_rate" && !c.topReqRates[hostname]:
+		return otherHosts, handler
+	case section == "extapp" && !c.topExtApps[hostname]:
+		// Handlers are often spawned for every site too
+		return otherHosts, otherHosts
+	}
+	return hostname, handler
+}
+
+// foldHosts sums up the REQ_RATE and EXTAPP lines of the hosts beyond the top
+// ones into a single __other__ host
+func (c *LitespeedCollector) foldHosts(reports map[string]rtreport.Report) {
+	for core, report := range reports {
+		folded := rtreport.Report{}
+		for _, rrReport := range report.ReqRates {
+			rrReport.Hostname, _ = c.foldedHost("req_rate", rrReport.Hostname, "")
+			folded.ReqRates = append(folded.ReqRates, rrReport)
+		}
+		for _, eaReport := range report.ExtApps {
+			eaReport.Hostname, eaReport.Handler = c.foldedHost("extapp", eaReport.Hostname, eaReport.Handler)
+			folded.ExtApps = append(folded.ExtApps, eaReport)
+		}
+
+		// Adding the lines sums up the ones of the same host
+		merged := rtreport.NewReport()
+		merged.Add(folded)
+		report.ReqRates, report.ExtApps = merged.ReqRates, merged.ExtApps
+		reports[core] = report
+	}
+}
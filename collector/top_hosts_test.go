@@ -0,0 +1,192 @@
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/hostinger/litespeed_exporter/rtreport"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostRankerTop(t *testing.T) {
+	r := newHostRanker(time.Minute)
+	r.update(map[string]float64{"a.com": 1, "b.com": 5, "c.com": 5, "d.com": 3}, time.Unix(0, 0))
+
+	assert.Equal(t, map[string]bool{"b.com": true, "c.com": true}, r.top(2))
+	assert.Len(t, r.top(10), 4)
+}
+
+func TestHostRankerSmoothsScores(t *testing.T) {
+	r := newHostRanker(10 * time.Minute)
+	now := time.Unix(0, 0)
+	r.update(map[string]float64{"a.com": 10, "b.com": 1}, now)
+
+	// A single spike doesn't move a host into the top ones...
+	now = now.Add(15 * time.Second)
+	r.update(map[string]float64{"a.com": 10, "b.com": 50}, now)
+	assert.Equal(t, map[string]bool{"a.com": true}, r.top(1))
+
+	// ...but a lasting change does
+	for i := 0; i < 40; i++ {
+		now = now.Add(15 * time.Second)
+		r.update(map[string]float64{"a.com": 10, "b.com": 50}, now)
+	}
+	assert.Equal(t, map[string]bool{"b.com": true}, r.top(1))
+}
+
+func TestHostRankerForgetsMissingHosts(t *testing.T) {
+	r := newHostRanker(time.Second)
+	now := time.Unix(0, 0)
+	r.update(map[string]float64{"a.com": 1, "b.com": 1}, now)
+
+	r.update(map[string]float64{"a.com": 1}, now.Add(time.Second))
+	assert.Contains(t, r.scores, "b.com")
+	assert.Less(t, r.scores["b.com"], 1.0)
+
+	r.update(map[string]float64{"a.com": 1}, now.Add(time.Minute))
+	assert.NotContains(t, r.scores, "b.com")
+}
+
+func TestFoldHosts(t *testing.T) {
+	c := NewLitespeedCollector(LitespeedCollectorOpts{TopHosts: 1}, log.NewNopLogger())
+	reports := map[string]rtreport.Report{
+		"": {
+			ReqRates: []rtreport.RequestRate{
+				{Hostname: "", KeyValues: map[string]float64{reqRateReqPerSecField: 10, reqRateTotReqsField: 100}},
+				{Hostname: "a.com", KeyValues: map[string]float64{reqRateReqPerSecField: 5, reqRateTotReqsField: 50}},
+				{Hostname: "b.com", KeyValues: map[string]float64{reqRateReqPerSecField: 3, reqRateTotReqsField: 30}},
+				{Hostname: "c.com", KeyValues: map[string]float64{reqRateReqPerSecField: 2, reqRateTotReqsField: 20}},
+			},
+			ExtApps: []rtreport.ExternalApp{
+				{Service: "LSAPI", Hostname: "", Handler: "lsphp", KeyValues: map[string]float64{extappReqPerSecField: 1}},
+				{Service: "LSAPI", Hostname: "a.com", Handler: "a.com_php", KeyValues: map[string]float64{extappReqPerSecField: 1}},
+				{Service: "LSAPI", Hostname: "b.com", Handler: "b.com_php", KeyValues: map[string]float64{extappReqPerSecField: 4}},
+				{Service: "LSAPI", Hostname: "c.com", Handler: "c.com_php", KeyValues: map[string]float64{extappReqPerSecField: 2}},
+			},
+		},
+	}
+
+	c.rankHosts(reports)
+	c.foldHosts(reports)
+
+	assert.Equal(t, []rtreport.RequestRate{
+		{Hostname: "", KeyValues: map[string]float64{reqRateReqPerSecField: 10, reqRateTotReqsField: 100}},
+		{Hostname: "a.com", KeyValues: map[string]float64{reqRateReqPerSecField: 5, reqRateTotReqsField: 50}},
+		{Hostname: otherHosts, KeyValues: map[string]float64{reqRateReqPerSecField: 5, reqRateTotReqsField: 50}},
+	}, reports[""].ReqRates)
+	assert.Equal(t, []rtreport.ExternalApp{
+		{Service: "LSAPI", Hostname: "", Handler: "lsphp", KeyValues: map[string]float64{extappReqPerSecField: 1}},
+		{Service: "LSAPI", Hostname: otherHosts, Handler: otherHosts, KeyValues: map[string]float64{extappReqPerSecField: 3}},
+		{Service: "LSAPI", Hostname: "b.com", Handler: "b.com_php", KeyValues: map[string]float64{extappReqPerSecField: 4}},
+	}, reports[""].ExtApps)
+}
+
+func TestTopHostsFoldsOtherHosts(t *testing.T) {
+	f, _ := ioutil.TempFile("", "TestTopHostsFoldsOtherHosts")
+	defer os.Remove(f.Name())
+
+	c := NewLitespeedCollector(
+		LitespeedCollectorOpts{
+			FilePattern:     f.Name(),
+			ReqRatesByHost:  true,
+			MetricsByCore:   false,
+			ExcludeExtapp:   false,
+			ExcludedMetrics: ParseFlagsToMap([]string{}),
+			TopHosts:        1,
+			TopHostsBy:      "TOT_REQS",
+			TopHostsWindow:  time.Hour,
+		},
+		log.NewNopLogger(),
+	)
+
+	write := func(a, b, c float64) {
+		ioutil.WriteFile(f.Name(), []byte(fmt.Sprintf(`VERSION: LiteSpeed Web Server/Open/1.6.18
+BPS_IN: 1, BPS_OUT: 2
+REQ_RATE []: REQ_PROCESSING: 0, TOT_REQS: 1000
+REQ_RATE [a.com]: REQ_PROCESSING: 0, TOT_REQS: %v
+REQ_RATE [b.com]: REQ_PROCESSING: 0, TOT_REQS: %v
+REQ_RATE [c.com]: REQ_PROCESSING: 0, TOT_REQS: %v
+EOF
+`, a, b, c)), 0644)
+	}
+	expected := func(host string, hostReqs, otherReqs float64) string {
+		return fmt.Sprintf(`
+# HELP litespeed_requests_total Total number of requests served.
+# TYPE litespeed_requests_total counter
+litespeed_requests_total{core="",hostname=""} 1000
+litespeed_requests_total{core="",hostname="%s"} %v
+litespeed_requests_total{core="",hostname="__other__"} %v
+`, host, hostReqs, otherReqs)
+	}
+
+	write(500, 100, 50)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected("a.com", 500, 150)), "litespeed_requests_total"); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+
+	// b.com stays folded until its smoothed score overtakes a.com
+	write(500, 600, 50)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected("a.com", 500, 650)), "litespeed_requests_total"); err != nil {
+		t.Fatal("Metrics not equal:", err)
+	}
+}
+
+func TestTopHostsKeepsOtherCountersMonotonic(t *testing.T) {
+	f, _ := ioutil.TempFile("", "TestTopHostsKeepsOtherCountersMonotonic")
+	defer os.Remove(f.Name())
+
+	for _, byCore := range []bool{false, true} {
+		c := NewLitespeedCollector(
+			LitespeedCollectorOpts{
+				FilePattern:     f.Name(),
+				ReqRatesByHost:  true,
+				MetricsByCore:   byCore,
+				ExcludeExtapp:   false,
+				ExcludedMetrics: ParseFlagsToMap([]string{}),
+				TopHosts:        1,
+				TopHostsBy:      "REQ_PER_SEC",
+				TopHostsWindow:  time.Nanosecond,
+			},
+			log.NewNopLogger(),
+		)
+		core := ""
+		if byCore {
+			core = f.Name()
+		}
+
+		write := func(aRate, aReqs, bRate, bReqs float64) {
+			ioutil.WriteFile(f.Name(), []byte(fmt.Sprintf(`VERSION: LiteSpeed Web Server/Open/1.6.18
+BPS_IN: 1, BPS_OUT: 2
+REQ_RATE [a.com]: REQ_PER_SEC: %v, TOT_REQS: %v
+REQ_RATE [b.com]: REQ_PER_SEC: %v, TOT_REQS: %v
+EOF
+`, aRate, aReqs, bRate, bReqs)), 0644)
+		}
+		expected := func(host string, hostReqs, otherReqs float64) string {
+			return fmt.Sprintf(`
+# HELP litespeed_requests_total Total number of requests served.
+# TYPE litespeed_requests_total counter
+litespeed_requests_total{core=%[1]q,hostname=%[2]q} %[3]v
+litespeed_requests_total{core=%[1]q,hostname="__other__"} %[4]v
+`, core, host, hostReqs, otherReqs)
+		}
+
+		write(10, 10, 1, 1000)
+		if err := testutil.CollectAndCompare(c, strings.NewReader(expected("a.com", 10, 1000)), "litespeed_requests_total"); err != nil {
+			t.Fatal("Metrics not equal:", err)
+		}
+
+		// __other__ doesn't drop when b.com enters the top ones, b.com only
+		// counting the requests it served since
+		write(1, 20, 10, 1010)
+		if err := testutil.CollectAndCompare(c, strings.NewReader(expected("b.com", 10, 1010)), "litespeed_requests_total"); err != nil {
+			t.Fatal("Metrics not equal:", err)
+		}
+	}
+}
@@ -1,63 +1,48 @@
 package collector
 
 import (
-	"strconv"
-	"strings"
+	"os"
+	"time"
 )
 
-func sumOrAppend(kv map[string]float64, k string, v float64) {
-	if _, ok := kv[k]; ok {
-		kv[k] += v
-	} else {
-		kv[k] = v
+// ParseFlagsToMap converts array of strings to a boolean map
+func ParseFlagsToMap(s []string) map[string]bool {
+	m := map[string]bool{}
+	for _, v := range s {
+		m[v] = true
 	}
+	return m
 }
 
-func parseMetricValue(flag string, value string) (float64, error) {
-	var valueInt int64
-	var valueFloat float64
-	var err error
-
-	switch flag {
-	case reqRateReqPerSecField, reqRatePubCacheHitsPerSecField, reqRatePrivateCacheHitsPerSecField, reqRateStaticHitsPerSecField, extappReqPerSecField:
-		valueFloat, err = strconv.ParseFloat(value, 64)
-		if err != nil {
-			return 0, err
-		}
-	default:
-		valueInt, err = strconv.ParseInt(value, 10, 64)
-		if err != nil {
-			return 0, err
-		}
-		valueFloat = float64(valueInt)
+// modTimes records the modification times of the files a configuration was
+// read from, so that it's only read again when one of them changes. Missing
+// files are recorded with a zero time.
+type modTimes map[string]time.Time
+
+// add records the modification time of a file, before it's read so that
+// changes made while reading aren't missed
+func (m modTimes) add(fileName string) {
+	fi, err := os.Stat(fileName)
+	if err != nil {
+		m[fileName] = time.Time{}
+		return
 	}
-
-	return valueFloat, nil
+	m[fileName] = fi.ModTime()
 }
 
-func parseKeyValPair(keyVal string, separator string) (string, string) {
-	parts := strings.Split(keyVal, separator)
-	return parts[0], parts[1]
-}
-
-func parseKeyValLineToMap(line string) map[string]string {
-	mapped := make(map[string]string)
-	parts := strings.Split(line, ", ")
-	for _, pair := range parts {
-		separator := ": "
-		if strings.Contains(pair, separator) {
-			key, value := parseKeyValPair(pair, separator)
-			mapped[key] = value
+// changed tells whether any of the files was modified, created or removed since it was recorded
+func (m modTimes) changed() bool {
+	for fileName, modTime := range m {
+		fi, err := os.Stat(fileName)
+		if err != nil {
+			if !modTime.IsZero() {
+				return true
+			}
+			continue
+		}
+		if !fi.ModTime().Equal(modTime) {
+			return true
 		}
 	}
-	return mapped
-}
-
-// ParseFlagsToMap converts array of strings to a boolean map
-func ParseFlagsToMap(s []string) map[string]bool {
-	m := map[string]bool{}
-	for _, v := range s {
-		m[v] = true
-	}
-	return m
+	return false
 }
@@ -36,6 +36,46 @@ func main() {
 		litespeedReqRatesByHost  = kingpin.Flag("litespeed.req-rates-by-host", "Export Request Rates by host.").Bool()
 		litespeedMetricsByCore   = kingpin.Flag("litespeed.metrics-by-core", "Export metrics by core filename.").Bool()
 		litespeedExcludeExtapp   = kingpin.Flag("litespeed.exclude-extapp", "Exclude EXTAPP metrics altogether.").Bool()
+		litespeedBlockedIPsTop   = kingpin.Flag("litespeed.blocked-ips-top", "Export the N most blocked IP addresses, capped at 100 (0 disables).").Default("0").Int()
+		litespeedBlockedIPsByPfx = kingpin.Flag("litespeed.blocked-ips-by-prefix", "Group the top blocked IP addresses by /24 (IPv4) or /64 (IPv6) prefix.").Bool()
+		litespeedMetricMapping   = kingpin.Flag("litespeed.metric-mapping-file", "Path to a YAML file declaring how report fields are exported, overriding or extending the built-in metrics.").Default("").String()
+		litespeedExportUnknown   = kingpin.Flag("litespeed.export-unknown-metrics", "Export report fields unknown to the exporter as litespeed_unknown_* gauges.").Bool()
+		litespeedRetries         = kingpin.Flag("litespeed.incomplete-retries", "Number of times a partially written report is read again before it's skipped.").Default("3").Int()
+		litespeedRetryBackoff    = kingpin.Flag("litespeed.incomplete-retry-backoff", "Initial wait before reading a partially written report again, doubled on every retry up to 1s.").Default("50ms").Duration()
+		litespeedHandlerUsers    = kingpin.Flag("litespeed.handler-users", "Label the EXTAPP metrics of the handlers spawned for every user, such as lsphp.10000, with the name of the user.").Bool()
+		litespeedPIDFile         = kingpin.Flag("litespeed.pid-file", "Path of the file lshttpd writes its PID to.").Default(collector.DefaultPIDFile).String()
+		litespeedStaleAfter      = kingpin.Flag("litespeed.stale-after", "Age above which a report is considered stale and left out, as when LiteSpeed hangs (0 disables).").Default("1m").Duration()
+		litespeedSampleInterval  = kingpin.Flag("litespeed.sample-interval", "Interval at which reports are parsed between scrapes to record the highs and lows of the sampled metrics (0 disables).").Default("0s").Duration()
+		litespeedSampledMetrics  = kingpin.Flag("litespeed.sampled-metrics", "Comma-separated list of gauge metrics to sample between scrapes.").Default("PLAINCONN,SSLCONN,REQ_RATE_REQ_PROCESSING,EXTAPP_INUSE_CONN,EXTAPP_WAITQUE_DEPTH").String()
+		litespeedTopHosts        = kingpin.Flag("litespeed.top-hosts", "Number of hosts exported with their own request rate and external application series, the others being summed up under the __other__ host (0 exports all hosts).").Default("0").Int()
+		litespeedTopHostsBy      = kingpin.Flag("litespeed.top-hosts-by", "Field hosts are ranked by when top hosts is set.").Default(collector.DefaultTopHostsBy).Enum("REQ_PER_SEC", "TOT_REQS")
+		litespeedTopHostsWindow  = kingpin.Flag("litespeed.top-hosts-window", "Window over which the host rankings are smoothed so that hosts don't move in and out of the top ones.").Default("10m").Duration()
+		reqRateHostsInclude      = kingpin.Flag("litespeed.req-rate-hosts.include", "Regular expression matching the hostnames of the virtual host request rates to keep, all by default.").Regexp()
+		reqRateHostsExclude      = kingpin.Flag("litespeed.req-rate-hosts.exclude", "Regular expression matching the hostnames of the virtual host request rates to drop.").Regexp()
+		extappServicesInclude    = kingpin.Flag("litespeed.extapp-services.include", "Regular expression matching the services of the external applications to keep, all by default.").Regexp()
+		extappServicesExclude    = kingpin.Flag("litespeed.extapp-services.exclude", "Regular expression matching the services of the external applications to drop.").Regexp()
+		extappHostsInclude       = kingpin.Flag("litespeed.extapp-hosts.include", "Regular expression matching the hostnames of the external applications to keep, all by default.").Regexp()
+		extappHostsExclude       = kingpin.Flag("litespeed.extapp-hosts.exclude", "Regular expression matching the hostnames of the external applications to drop.").Regexp()
+		extappHandlersInclude    = kingpin.Flag("litespeed.extapp-handlers.include", "Regular expression matching the handlers of the external applications to keep, all by default.").Regexp()
+		extappHandlersExclude    = kingpin.Flag("litespeed.extapp-handlers.exclude", "Regular expression matching the handlers of the external applications to drop.").Regexp()
+		litespeedInventoryFile   = kingpin.Flag("litespeed.inventory-file", "Path to a CSV, JSON or YAML file mapping hostnames or hostname globs to the labels exported by litespeed_vhost_inventory_info.").Default("").String()
+		litespeedParseMode       = kingpin.Flag("litespeed.parse-mode", "How malformed report lines are handled: strict rejects the whole report, lenient only skips the line.").Default(collector.ParseModeStrict).Enum(collector.ParseModeStrict, collector.ParseModeLenient)
+		litespeedSource          = kingpin.Flag("litespeed.source", "Where reports are read from: file reads the files matching the scrape pattern, webadmin fetches the real-time report from the WebAdmin console.").Default(collector.SourceFile).Enum(collector.SourceFile, collector.SourceWebAdmin)
+		webAdminURL              = kingpin.Flag("litespeed.webadmin-url", "URL of the WebAdmin real-time report.").Default("https://localhost:7080/status?rpt=summary").String()
+		webAdminUsername         = kingpin.Flag("litespeed.webadmin-user", "User name used to log into WebAdmin.").Default("").String()
+		webAdminPassword         = kingpin.Flag("litespeed.webadmin-password", "Password used to log into WebAdmin.").Default("").Envar("LITESPEED_WEBADMIN_PASSWORD").String()
+		webAdminInsecure         = kingpin.Flag("litespeed.webadmin-insecure", "Skip the verification of the WebAdmin TLS certificate.").Bool()
+		collectProcesses         = kingpin.Flag("collector.process", "Export the resource usage of the lshttpd processes read from procfs.").Default("true").Bool()
+		collectExtappProcesses   = kingpin.Flag("collector.extapp-processes", "Export the resource usage of the external application processes, grouped by handler and user, which reads every process on each scrape.").Bool()
+		extappProcessNames       = kingpin.Flag("collector.extapp-processes.names", "Regular expression matching the names of the external application processes.").Default(collector.DefaultExtappProcessNames.String()).Regexp()
+		passwdFile               = kingpin.Flag("path.passwd", "passwd file the UIDs of the external application processes and handlers are resolved with.").Default(collector.DefaultPasswdFile).String()
+		procfsPath               = kingpin.Flag("path.procfs", "procfs mountpoint.").Default("/proc").String()
+		collectConfig            = kingpin.Flag("collector.config", "Export the virtual hosts, listeners and external application limits declared in the LiteSpeed configuration.").Bool()
+		serverRoot               = kingpin.Flag("path.server-root", "Directory LiteSpeed is installed in, its configuration being read from conf/httpd_config.xml or conf/httpd_config.conf.").Default(collector.DefaultServerRoot).String()
+		collectApacheConfig      = kingpin.Flag("collector.apache-config", "Export the domains, aliases and users of the virtual hosts LSWS reads from the Apache configuration of control panels.").Bool()
+		apacheConfigFile         = kingpin.Flag("path.apache-config", "Main Apache configuration file, the files it includes being read too.").Default(collector.DefaultApacheConfigFile).String()
+		exportRatios             = kingpin.Flag("collector.ratios", "Export ratios derived from the report, such as connection utilization and cache hit ratio.").Bool()
+		legacyMetricNames        = kingpin.Flag("compat.legacy-metric-names", "Also export metrics under the names used by previous releases, while dashboards are migrated.").Bool()
 	)
 
 	promlogConfig := &promlog.Config{}
@@ -46,20 +86,102 @@ func main() {
 	kingpin.Version(fmt.Sprintf("%s v%s (%s %s)", exporter, Version, Date, Revision))
 	kingpin.Parse()
 
+	if *litespeedMetricMapping != "" {
+		if err := collector.LoadMetricMappings(*litespeedMetricMapping); err != nil {
+			level.Error(logger).Log("msg", "Could not load metric mapping file", "err", err)
+			os.Exit(1)
+		}
+	}
+
 	excludedMetricFlags := strings.Split(*litespeedExcludedMetrics, ",")
 
-	collector := collector.NewLitespeedCollector(
+	litespeedCollector := collector.NewLitespeedCollector(
 		collector.LitespeedCollectorOpts{
-			FilePattern:     *litespeedScrapePattern,
-			ReqRatesByHost:  *litespeedReqRatesByHost,
-			MetricsByCore:   *litespeedMetricsByCore,
-			ExcludeExtapp:   *litespeedExcludeExtapp,
-			ExcludedMetrics: collector.ParseFlagsToMap(excludedMetricFlags),
+			FilePattern:            *litespeedScrapePattern,
+			ReqRatesByHost:         *litespeedReqRatesByHost,
+			MetricsByCore:          *litespeedMetricsByCore,
+			ExcludeExtapp:          *litespeedExcludeExtapp,
+			ExcludedMetrics:        collector.ParseFlagsToMap(excludedMetricFlags),
+			ReqRateHosts:           collector.NameFilter{Include: *reqRateHostsInclude, Exclude: *reqRateHostsExclude},
+			ExtappServices:         collector.NameFilter{Include: *extappServicesInclude, Exclude: *extappServicesExclude},
+			ExtappHosts:            collector.NameFilter{Include: *extappHostsInclude, Exclude: *extappHostsExclude},
+			ExtappHandlers:         collector.NameFilter{Include: *extappHandlersInclude, Exclude: *extappHandlersExclude},
+			InventoryFile:          *litespeedInventoryFile,
+			BlockedIPsTopN:         *litespeedBlockedIPsTop,
+			BlockedIPsByPrefix:     *litespeedBlockedIPsByPfx,
+			ExportUnknownMetrics:   *litespeedExportUnknown,
+			IncompleteRetries:      *litespeedRetries,
+			IncompleteRetryBackoff: *litespeedRetryBackoff,
+			PIDFile:                *litespeedPIDFile,
+			HandlerUsers:           *litespeedHandlerUsers,
+			PasswdFile:             *passwdFile,
+			StaleAfter:             *litespeedStaleAfter,
+			SampleInterval:         *litespeedSampleInterval,
+			SampledMetrics:         collector.ParseFlagsToMap(strings.Split(*litespeedSampledMetrics, ",")),
+			TopHosts:               *litespeedTopHosts,
+			TopHostsBy:             *litespeedTopHostsBy,
+			TopHostsWindow:         *litespeedTopHostsWindow,
+			ParseMode:              *litespeedParseMode,
+			Source:                 *litespeedSource,
+			WebAdminURL:            *webAdminURL,
+			WebAdminUsername:       *webAdminUsername,
+			WebAdminPassword:       *webAdminPassword,
+			WebAdminInsecure:       *webAdminInsecure,
+			LegacyMetricNames:      *legacyMetricNames,
+			ExportRatios:           *exportRatios,
 		},
 		logger,
 	)
 
-	prometheus.MustRegister(collector)
+	prometheus.MustRegister(litespeedCollector)
+	go litespeedCollector.RunSampler(nil)
+
+	if *collectProcesses {
+		processCollector, err := collector.NewProcessCollector(
+			collector.ProcessCollectorOpts{
+				PIDFile:    *litespeedPIDFile,
+				ProcfsPath: *procfsPath,
+			},
+			logger,
+		)
+		if err != nil {
+			// procfs can be missing or restricted, such as in containers
+			level.Warn(logger).Log("msg", "Could not create process collector, skipping it", "err", err)
+		} else {
+			prometheus.MustRegister(processCollector)
+		}
+	}
+
+	if *collectExtappProcesses {
+		extappProcessCollector, err := collector.NewExtappProcessCollector(
+			collector.ExtappProcessCollectorOpts{
+				ProcfsPath:   *procfsPath,
+				PasswdFile:   *passwdFile,
+				ProcessNames: *extappProcessNames,
+				Handlers:     litespeedCollector.Handlers,
+			},
+			logger,
+		)
+		if err != nil {
+			level.Error(logger).Log("msg", "Could not create external application process collector", "err", err)
+			os.Exit(1)
+		}
+		prometheus.MustRegister(extappProcessCollector)
+	}
+
+	if *collectConfig {
+		prometheus.MustRegister(collector.NewConfigCollector(collector.ConfigCollectorOpts{ServerRoot: *serverRoot}, logger))
+	}
+
+	if *collectApacheConfig {
+		prometheus.MustRegister(collector.NewApacheConfigCollector(
+			collector.ApacheConfigCollectorOpts{
+				ConfigFile: *apacheConfigFile,
+				Hostnames:  litespeedCollector.Hostnames,
+			},
+			logger,
+		))
+	}
 
 	level.Info(logger).Log("build", version.Info())
 	level.Info(logger).Log("address", *listenAddress)
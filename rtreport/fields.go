@@ -0,0 +1,40 @@
+package rtreport
+
+// Names of the fields found in a report. Fields of the REQ_RATE and EXTAPP
+// lines are prefixed with the name of the line they're read from.
+const (
+	VersionField                       = "VERSION"
+	UptimeField                        = "UPTIME"
+	BpsInField                         = "BPS_IN"
+	BpsOutField                        = "BPS_OUT"
+	SslBpsInField                      = "SSL_BPS_IN"
+	SslBpsOutField                     = "SSL_BPS_OUT"
+	MaxconnField                       = "MAXCONN"
+	MaxsslConnField                    = "MAXSSL_CONN"
+	PlainconnField                     = "PLAINCONN"
+	AvailconnField                     = "AVAILCONN"
+	IdleconnField                      = "IDLECONN"
+	SslconnField                       = "SSLCONN"
+	AvailsslField                      = "AVAILSSL"
+	ReqRateField                       = "REQ_RATE"
+	ReqRateReqProcessingField          = "REQ_RATE_REQ_PROCESSING"
+	ReqRateReqPerSecField              = "REQ_RATE_REQ_PER_SEC"
+	ReqRateTotReqsField                = "REQ_RATE_TOT_REQS"
+	ReqRatePubCacheHitsPerSecField     = "REQ_RATE_PUB_CACHE_HITS_PER_SEC"
+	ReqRateTotalPubCacheHitsField      = "REQ_RATE_TOTAL_PUB_CACHE_HITS"
+	ReqRatePrivateCacheHitsPerSecField = "REQ_RATE_PRIVATE_CACHE_HITS_PER_SEC"
+	ReqRateTotalPrivateCacheHitsField  = "REQ_RATE_TOTAL_PRIVATE_CACHE_HITS"
+	ReqRateStaticHitsPerSecField       = "REQ_RATE_STATIC_HITS_PER_SEC"
+	ReqRateTotalStaticHitsField        = "REQ_RATE_TOTAL_STATIC_HITS"
+	ExtappField                        = "EXTAPP"
+	ExtappCmaxconnField                = "EXTAPP_CMAXCONN"
+	ExtappEmaxconnField                = "EXTAPP_EMAXCONN"
+	ExtappPoolSizeField                = "EXTAPP_POOL_SIZE"
+	ExtappInuseConnField               = "EXTAPP_INUSE_CONN"
+	ExtappIdleConnField                = "EXTAPP_IDLE_CONN"
+	ExtappWaitqueDepthField            = "EXTAPP_WAITQUE_DEPTH"
+	ExtappReqPerSecField               = "EXTAPP_REQ_PER_SEC"
+	ExtappTotReqsField                 = "EXTAPP_TOT_REQS"
+	BlockedIPField                     = "BLOCKED_IP"
+	EOFMarker                          = "EOF"
+)
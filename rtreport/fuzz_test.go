@@ -0,0 +1,77 @@
+//go:build go1.18
+// +build go1.18
+
+package rtreport_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/hostinger/litespeed_exporter/collector"
+	"github.com/hostinger/litespeed_exporter/rtreport"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// FuzzParse checks that no input makes the parser panic, whatever its mode,
+// nor the collector exporting the reports it parses. It's seeded with the
+// reports found in the testdata directory.
+func FuzzParse(f *testing.F) {
+	seeds, err := filepath.Glob(filepath.Join("..", "testdata", "*report*"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, seed := range seeds {
+		data, err := ioutil.ReadFile(seed)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, p := range []rtreport.Parser{{}, {Lenient: true, KeepBlockedIPs: true}, {SkipHostReqRates: true, SkipExtApps: true}} {
+			report, err := p.Parse(bytes.NewReader(data))
+			if err != nil {
+				if report != nil {
+					t.Fatalf("Parse returned both a report and error %v", err)
+				}
+				continue
+			}
+
+			rtreport.ParseUptime(report.GeneralInfo.Uptime)
+			rtreport.Merge(*report, *report)
+		}
+
+		fileName := filepath.Join(t.TempDir(), ".rtreport")
+		if err := ioutil.WriteFile(fileName, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, opts := range []collector.LitespeedCollectorOpts{
+			{ParseMode: collector.ParseModeStrict, ReqRatesByHost: true, MetricsByCore: true},
+			{ParseMode: collector.ParseModeLenient, ReqRatesByHost: true, ExportUnknownMetrics: true, LegacyMetricNames: true, BlockedIPsTopN: 3},
+			{ParseMode: collector.ParseModeLenient, ReqRatesByHost: true, MetricsByCore: true, HandlerUsers: true, ExportRatios: true, TopHosts: 1},
+		} {
+			opts.FilePattern = fileName
+			opts.ExcludedMetrics = collector.ParseFlagsToMap([]string{})
+			collect(collector.NewLitespeedCollector(opts, log.NewNopLogger()))
+		}
+	})
+}
+
+// collect runs Collect in the calling goroutine, so that a panic fails the fuzz target
+func collect(c prometheus.Collector) {
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for range ch {
+		}
+		close(done)
+	}()
+
+	c.Collect(ch)
+	close(ch)
+	<-done
+}
@@ -0,0 +1,494 @@
+// Package rtreport parses the real-time reports LiteSpeed Web Server writes
+// to its runtime directory, usually as /tmp/lshttpd/.rtreport for the first
+// worker process and /tmp/lshttpd/.rtreport.N for the others.
+package rtreport
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// maxLineSize bounds the length of a single report line
+const maxLineSize = 1024 * 1024
+
+// ErrIncomplete is returned when a report misses its VERSION, BPS_IN or
+// trailing EOF line, which happens when reading a file LiteSpeed is rewriting
+var ErrIncomplete = errors.New("rtreport: incomplete report")
+
+// ParseError describes a line that couldn't be parsed
+type ParseError struct {
+	// File is the path or name of the report, only set by ParseFile and ParseNamed
+	File string
+	Line int
+	// Section is the kind of line, such as "req_rate", "extapp" or "general"
+	Section string
+	// Reason is a short snake_case description, such as "missing_hostname" or "invalid_value"
+	Reason string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("line %d: %s: %s", e.Line, e.Section, e.Reason)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.File != "" {
+		msg = e.File + ": " + msg
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error, if any
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// FieldError describes a field whose value couldn't be parsed
+type FieldError struct {
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("can't parse value %q of field %s: %s", e.Value, e.Field, e.Err)
+}
+
+// Unwrap returns the underlying parsing error
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Parser parses reports. The zero value keeps every line and field, and
+// rejects reports holding malformed lines.
+type Parser struct {
+	// Lenient skips malformed lines instead of rejecting the whole report
+	Lenient bool
+	// SkipHostReqRates skips the REQ_RATE lines of virtual hosts, only keeping the server wide one
+	SkipHostReqRates bool
+	// SkipExtApps skips the EXTAPP lines altogether
+	SkipExtApps bool
+	// KeepBlockedIPs keeps the addresses of the BLOCKED_IP line, which are otherwise only counted
+	KeepBlockedIPs bool
+	// SkipReqRate, when set, is called with the hostname of every virtual host
+	// REQ_RATE line and skips the ones it returns true for
+	SkipReqRate func(hostname string) bool
+	// SkipExtApp, when set, is called with the service, hostname and handler
+	// of every EXTAPP line and skips the ones it returns true for
+	SkipExtApp func(service, hostname, handler string) bool
+	// SkipField, when set, is called with the name of every field and skips the ones it returns true for
+	SkipField func(field string) bool
+	// ErrorHandler, when set, is called with a *ParseError for every line and
+	// every value skipped in lenient mode, the errors of values wrapping a
+	// *FieldError. Such lines and values are left out of the report without
+	// failing the whole parse, which they do in strict mode.
+	ErrorHandler func(err error)
+}
+
+// parser holds the state of a single parse
+type parser struct {
+	*Parser
+	file    string
+	line    int
+	section string
+	report  *Report
+}
+
+var (
+	pairSeparator   = []byte(", ")
+	keyValSeparator = []byte(": ")
+
+	// Known field names by the key they're written with, so that parsing
+	// known fields never allocates a new string
+	generalFieldNames = fieldNames("",
+		BpsInField, BpsOutField, SslBpsInField, SslBpsOutField,
+		MaxconnField, MaxsslConnField, PlainconnField, AvailconnField, IdleconnField, SslconnField, AvailsslField,
+	)
+	reqRateFieldNames = fieldNames(ReqRateField,
+		ReqRateReqProcessingField, ReqRateReqPerSecField, ReqRateTotReqsField,
+		ReqRatePubCacheHitsPerSecField, ReqRateTotalPubCacheHitsField,
+		ReqRatePrivateCacheHitsPerSecField, ReqRateTotalPrivateCacheHitsField,
+		ReqRateStaticHitsPerSecField, ReqRateTotalStaticHitsField,
+	)
+	extappFieldNames = fieldNames(ExtappField,
+		ExtappCmaxconnField, ExtappEmaxconnField, ExtappPoolSizeField, ExtappInuseConnField,
+		ExtappIdleConnField, ExtappWaitqueDepthField, ExtappReqPerSecField, ExtappTotReqsField,
+	)
+
+	lineBuffers = sync.Pool{
+		New: func() interface{} {
+			b := make([]byte, 64*1024)
+			return &b
+		},
+	}
+)
+
+func fieldNames(prefix string, fields ...string) map[string]string {
+	m := make(map[string]string, len(fields))
+	for _, f := range fields {
+		m[strings.TrimPrefix(f, prefix+"_")] = f
+	}
+	return m
+}
+
+// fieldName returns the name of the field written as key on a line of the
+// given kind, only allocating for fields unknown to this package
+func fieldName(names map[string]string, prefix string, key []byte) string {
+	if name, ok := names[string(key)]; ok {
+		return name
+	}
+	if prefix == "" {
+		return string(key)
+	}
+	return prefix + "_" + string(key)
+}
+
+// Parse parses a report with the default options
+func Parse(r io.Reader) (*Report, error) {
+	var p Parser
+	return p.Parse(r)
+}
+
+// ParseFile parses the report at the given path with the default options
+func ParseFile(fileName string) (*Report, error) {
+	var p Parser
+	return p.ParseFile(fileName)
+}
+
+// ParseFile parses the report at the given path
+func (p *Parser) ParseFile(fileName string) (*Report, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return p.ParseNamed(file, fileName)
+}
+
+// ParseNamed parses a report read from somewhere other than a file, the name
+// being reported as the File of parse errors
+func (p *Parser) ParseNamed(r io.Reader, name string) (*Report, error) {
+	return p.parse(r, name)
+}
+
+// Parse parses a report in place, line by line, without building
+// intermediate strings for the tokens. Malformed lines fail the whole parse
+// with a *ParseError unless the parser is lenient, and ErrIncomplete is
+// returned for truncated reports.
+func (p *Parser) Parse(r io.Reader) (*Report, error) {
+	return p.parse(r, "")
+}
+
+func (p *Parser) parse(r io.Reader, fileName string) (*Report, error) {
+	buf := lineBuffers.Get().(*[]byte)
+	defer lineBuffers.Put(buf)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(*buf, maxLineSize)
+
+	ps := &parser{Parser: p, file: fileName, report: NewReport()}
+	seenVersion, seenBPSIn, seenEOF := false, false, false
+
+	for scanner.Scan() {
+		ps.line++
+		line := scanner.Bytes()
+
+		identifier := lineIdentifier(line)
+		if len(identifier) == 0 {
+			continue
+		}
+
+		var err error
+		switch string(identifier) {
+		case EOFMarker:
+			seenEOF = true
+		case VersionField:
+			seenVersion = true
+			ps.section = "version"
+			ps.report.GeneralInfo.Version, err = ps.lineValue(line)
+		case UptimeField:
+			ps.section = "uptime"
+			ps.report.GeneralInfo.Uptime, err = ps.lineValue(line)
+		case BpsInField:
+			seenBPSIn = true
+			ps.section = "general"
+			err = ps.parseGeneralInfoLine(line)
+		case ReqRateField:
+			ps.section = "req_rate"
+			err = ps.parseReqRateLine(line[len(identifier):])
+		case ExtappField:
+			ps.section = "extapp"
+			if !p.SkipExtApps {
+				err = ps.parseExtAppLine(line[len(identifier):])
+			}
+		case BlockedIPField:
+			ps.section = "blocked_ip"
+			ps.parseBlockedIPLine(line[len(identifier):])
+		default:
+			// MAXCONN and lines unknown to this package carry server wide
+			// "KEY: value" pairs
+			if bytes.Contains(line, keyValSeparator) {
+				ps.section = "general"
+				err = ps.parseGeneralInfoLine(line)
+			}
+		}
+
+		if err != nil {
+			if !p.Lenient {
+				return nil, err
+			}
+			ps.handleError(err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			ps.line++
+			ps.section = ""
+			return nil, ps.errorf("line_too_long", err)
+		}
+		return nil, err
+	}
+
+	// LiteSpeed rewrites the reports every few seconds, so a read racing with
+	// a write sees a truncated file
+	if !seenEOF || !seenVersion || !seenBPSIn {
+		return nil, ErrIncomplete
+	}
+
+	return ps.report, nil
+}
+
+// errorf returns a *ParseError for the current line
+func (p *parser) errorf(reason string, err error) *ParseError {
+	return &ParseError{File: p.file, Line: p.line, Section: p.section, Reason: reason, Err: err}
+}
+
+func (p *parser) handleError(err error) {
+	if p.ErrorHandler != nil {
+		p.ErrorHandler(err)
+	}
+}
+
+func (p *Parser) skipField(flag string) bool {
+	return p.SkipField != nil && p.SkipField(flag)
+}
+
+func (p *parser) parseGeneralInfoLine(line []byte) error {
+	for key, value, rest, ok := nextKeyValPair(line); ok; key, value, rest, ok = nextKeyValPair(rest) {
+		flag := fieldName(generalFieldNames, "", key)
+		if p.skipField(flag) {
+			continue
+		}
+		// General lines lack the labels of the REQ_RATE and EXTAPP fields
+		if isLineField(flag) {
+			pe := p.errorf("misplaced_field", fmt.Errorf("field %s only belongs to REQ_RATE and EXTAPP lines", flag))
+			if !p.Lenient {
+				return pe
+			}
+			p.handleError(pe)
+			continue
+		}
+
+		vf, ok, err := p.parseFieldValue(flag, value)
+		if err != nil {
+			return err
+		}
+		if ok {
+			p.report.GeneralInfo.KeyValues[flag] = vf
+		}
+	}
+	return nil
+}
+
+// isLineField tells whether a field is named like the ones of the REQ_RATE and EXTAPP lines
+func isLineField(flag string) bool {
+	return strings.HasPrefix(flag, ReqRateField+"_") || strings.HasPrefix(flag, ExtappField+"_")
+}
+
+// parseReqRateLine parses the remainder of a "REQ_RATE [host]: KEY: value, ..." line
+func (p *parser) parseReqRateLine(line []byte) error {
+	hostname, line, ok := nextBracketed(line)
+	if !ok {
+		return p.errorf("missing_hostname", nil)
+	}
+	if len(hostname) > 0 && (p.SkipHostReqRates || p.SkipReqRate != nil && p.SkipReqRate(string(hostname))) {
+		return nil
+	}
+
+	line, ok = keyValues(line)
+	if !ok {
+		return p.errorf("missing_values", nil)
+	}
+
+	rr := RequestRate{
+		Hostname:  string(hostname),
+		KeyValues: make(map[string]float64, len(reqRateFieldNames)),
+	}
+	for key, value, rest, ok := nextKeyValPair(line); ok; key, value, rest, ok = nextKeyValPair(rest) {
+		flag := fieldName(reqRateFieldNames, ReqRateField, key)
+		if p.skipField(flag) {
+			continue
+		}
+
+		vf, ok, err := p.parseFieldValue(flag, value)
+		if err != nil {
+			return err
+		}
+		if ok {
+			rr.KeyValues[flag] = vf
+		}
+	}
+	p.report.ReqRates = append(p.report.ReqRates, rr)
+
+	return nil
+}
+
+// parseExtAppLine parses the remainder of an "EXTAPP [service] [host] [handler]: KEY: value, ..." line
+func (p *parser) parseExtAppLine(line []byte) error {
+	service, line, ok := nextBracketed(line)
+	if !ok {
+		return p.errorf("missing_service", nil)
+	}
+	hostname, line, ok := nextBracketed(line)
+	if !ok {
+		return p.errorf("missing_hostname", nil)
+	}
+	handler, line, ok := nextBracketed(line)
+	if !ok {
+		return p.errorf("missing_handler", nil)
+	}
+	if p.SkipExtApp != nil && p.SkipExtApp(string(service), string(hostname), string(handler)) {
+		return nil
+	}
+
+	line, ok = keyValues(line)
+	if !ok {
+		return p.errorf("missing_values", nil)
+	}
+
+	ea := ExternalApp{
+		Service:   string(service),
+		Hostname:  string(hostname),
+		Handler:   string(handler),
+		KeyValues: make(map[string]float64, len(extappFieldNames)),
+	}
+	for key, value, rest, ok := nextKeyValPair(line); ok; key, value, rest, ok = nextKeyValPair(rest) {
+		flag := fieldName(extappFieldNames, ExtappField, key)
+		if p.skipField(flag) {
+			continue
+		}
+
+		vf, ok, err := p.parseFieldValue(flag, value)
+		if err != nil {
+			return err
+		}
+		if ok {
+			ea.KeyValues[flag] = vf
+		}
+	}
+	p.report.ExtApps = append(p.report.ExtApps, ea)
+
+	return nil
+}
+
+// parseBlockedIPLine parses the remainder of a "BLOCKED_IP: address, ..." line
+func (p *parser) parseBlockedIPLine(line []byte) {
+	line = bytes.TrimPrefix(line, []byte(":"))
+
+	if p.KeepBlockedIPs {
+		p.report.GeneralInfo.BlockedIPs = parseBlockedIPs(line)
+	}
+	if !p.skipField(BlockedIPField) {
+		p.report.GeneralInfo.KeyValues[BlockedIPField] = float64(countBlockedIPs(line))
+	}
+}
+
+// parseFieldValue parses the value of a field. Invalid values fail the parse
+// in strict mode, and are only left out of the report in lenient mode.
+func (p *parser) parseFieldValue(flag string, value []byte) (float64, bool, error) {
+	vf, err := parseValue(flag, value)
+	if err == nil {
+		return vf, true, nil
+	}
+
+	pe := p.errorf("invalid_value", &FieldError{Field: flag, Value: string(value), Err: err})
+	if !p.Lenient {
+		return 0, false, pe
+	}
+	p.handleError(pe)
+	return 0, false, nil
+}
+
+// lineValue returns the value of a "KEY: value" line
+func (p *parser) lineValue(line []byte) (string, error) {
+	i := bytes.Index(line, keyValSeparator)
+	if i < 0 {
+		return "", p.errorf("missing_value", nil)
+	}
+	return string(line[i+len(keyValSeparator):]), nil
+}
+
+// lineIdentifier returns the leading word of a line, like `^\w*` does
+func lineIdentifier(line []byte) []byte {
+	for i, ch := range line {
+		if !isWordChar(ch) {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func isWordChar(ch byte) bool {
+	return ch == '_' || ('0' <= ch && ch <= '9') || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z')
+}
+
+// nextBracketed returns the content of the first "[...]" group of line and the
+// remainder of the line after it
+func nextBracketed(line []byte) (content, rest []byte, ok bool) {
+	start := bytes.IndexByte(line, '[')
+	if start < 0 {
+		return nil, line, false
+	}
+
+	end := bytes.IndexByte(line[start+1:], ']')
+	if end < 0 {
+		return nil, line, false
+	}
+
+	return line[start+1 : start+1+end], line[start+2+end:], true
+}
+
+// keyValues returns the "KEY: value, ..." part following the ": " that ends
+// the line prefix
+func keyValues(line []byte) ([]byte, bool) {
+	i := bytes.Index(line, keyValSeparator)
+	if i < 0 {
+		return nil, false
+	}
+	return line[i+len(keyValSeparator):], true
+}
+
+// nextKeyValPair returns the first "KEY: value" pair of a ", " separated line
+// and the remainder of the line. Pairs without a ": " separator are skipped.
+func nextKeyValPair(line []byte) (key, value, rest []byte, ok bool) {
+	for len(line) > 0 {
+		pair := line
+		line = nil
+		if i := bytes.Index(pair, pairSeparator); i >= 0 {
+			pair, line = pair[:i], pair[i+len(pairSeparator):]
+		}
+
+		if i := bytes.Index(pair, keyValSeparator); i >= 0 {
+			return pair[:i], pair[i+len(keyValSeparator):], line, true
+		}
+	}
+	return nil, nil, nil, false
+}
@@ -0,0 +1,418 @@
+package rtreport
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextKeyValPairReturnsExpected(t *testing.T) {
+	tests := []struct {
+		s string
+		e map[string]string
+	}{
+		{"", map[string]string{}},
+		{"TEST: true", map[string]string{"TEST": "true"}},
+		{"TEST 2: true too I guess", map[string]string{"TEST 2": "true too I guess"}},
+		{"REQ_PER_SEC: 123.45", map[string]string{"REQ_PER_SEC": "123.45"}},
+		{"TEST: true, NOT_TEST: false, RANDOM: 123.45", map[string]string{"TEST": "true", "NOT_TEST": "false", "RANDOM": "123.45"}},
+		{"TEST: true, GARBAGE, , RANDOM: 1,", map[string]string{"TEST": "true", "RANDOM": "1,"}},
+	}
+
+	for _, tc := range tests {
+		m := map[string]string{}
+		for key, value, rest, ok := nextKeyValPair([]byte(tc.s)); ok; key, value, rest, ok = nextKeyValPair(rest) {
+			m[string(key)] = string(value)
+		}
+		assert.Equal(t, tc.e, m)
+	}
+}
+
+func TestNextBracketedReturnsExpected(t *testing.T) {
+	tests := []struct {
+		s    string
+		e    string
+		rest string
+		ok   bool
+	}{
+		{" []: A: 1", "", ": A: 1", true},
+		{" [test.com]: A: 1", "test.com", ": A: 1", true},
+		{" [LSAPI] [] [lsphp]: A: 1", "LSAPI", " [] [lsphp]: A: 1", true},
+		{" [ABCDEFGH_php72:]: A: 1", "ABCDEFGH_php72:", ": A: 1", true},
+		{": A: 1", "", ": A: 1", false},
+		{" [test.com: A: 1", "", " [test.com: A: 1", false},
+	}
+
+	for _, tc := range tests {
+		content, rest, ok := nextBracketed([]byte(tc.s))
+		assert.Equal(t, tc.ok, ok)
+		assert.Equal(t, tc.e, string(content))
+		assert.Equal(t, tc.rest, string(rest))
+	}
+}
+
+func TestLineIdentifierReturnsExpected(t *testing.T) {
+	tests := []struct {
+		s string
+		e string
+	}{
+		{"", ""},
+		{"EOF", "EOF"},
+		{"REQ_RATE [test.com]: A: 1", "REQ_RATE"},
+		{"EXTAPP[localhost]", "EXTAPP"},
+		{" VERSION: 1", ""},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.e, string(lineIdentifier([]byte(tc.s))))
+	}
+}
+
+func TestParseRejectsMalformedLines(t *testing.T) {
+	tests := []string{
+		"VERSION",
+		"REQ_RATE: REQ_PROCESSING: 0",
+		"REQ_RATE [test.com] REQ_PROCESSING 0",
+		"EXTAPP[localhost] [lsphp.10000]: CMAXCONN: 10",
+		"EXTAPP [LSAPI] [localhost] [lsphp.10000 CMAXCONN: 10",
+	}
+
+	for _, tc := range tests {
+		r, err := Parse(strings.NewReader("VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1\n" + tc + "\nEOF\n"))
+		assert.Nil(t, r)
+		assert.Error(t, err, "Expected error for line %q", tc)
+	}
+}
+
+func TestParseReportsLineDiagnostics(t *testing.T) {
+	report := "VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1\n" +
+		"REQ_RATE [test.com]: REQ_PROCESSING: 0, TOT_REQS: 2\n" +
+		"REQ_RATE: REQ_PROCESSING: 0\n" +
+		"EXTAPP [LSAPI] [localhost]: CMAXCONN: 10\n" +
+		"EOF\n"
+
+	_, err := Parse(strings.NewReader(report))
+	assert.Equal(t, &ParseError{Line: 4, Section: "req_rate", Reason: "missing_hostname"}, err)
+
+	var errs []error
+	p := Parser{
+		Lenient: true,
+		ErrorHandler: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+	r, err := p.Parse(strings.NewReader(report))
+
+	assert.Nil(t, err)
+	assert.Len(t, r.ReqRates, 1)
+	assert.Equal(t, 2.0, r.ReqRates[0].KeyValues[ReqRateTotReqsField])
+	assert.Len(t, r.ExtApps, 0)
+	assert.Equal(t, []error{
+		&ParseError{Line: 4, Section: "req_rate", Reason: "missing_hostname"},
+		&ParseError{Line: 5, Section: "extapp", Reason: "missing_handler"},
+	}, errs)
+}
+
+func TestParseFileReportsFileName(t *testing.T) {
+	fileName := path.Join("..", "testdata", "malformed_report")
+
+	_, err := ParseFile(fileName)
+
+	var pe *ParseError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, fileName, pe.File)
+	assert.Equal(t, 6, pe.Line)
+	assert.Equal(t, fileName+": line 6: req_rate: missing_hostname", err.Error())
+}
+
+func TestParseRejectsTooLongLines(t *testing.T) {
+	report := "VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: " + strings.Repeat("1", maxLineSize) + "\nEOF\n"
+
+	_, err := Parse(strings.NewReader(report))
+
+	var pe *ParseError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, 2, pe.Line)
+	assert.Equal(t, "line_too_long", pe.Reason)
+}
+
+func TestParseDetectsIncompleteReports(t *testing.T) {
+	tests := []struct {
+		content string
+		want    error
+	}{
+		{"VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, BPS_OUT: 2\nEOF\n", nil},
+		{"VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, BPS_OUT: 2\nEOF", nil},
+		{"VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, BPS_OUT: 2\n", ErrIncomplete},
+		{"BPS_IN: 1, BPS_OUT: 2\nEOF\n", ErrIncomplete},
+		{"VERSION: LiteSpeed Web Server/Open/1.6.18\nEOF\n", ErrIncomplete},
+		{"", ErrIncomplete},
+	}
+
+	for _, tc := range tests {
+		_, err := Parse(strings.NewReader(tc.content))
+		assert.Equal(t, tc.want, err, "Unexpected error for report %q", tc.content)
+	}
+}
+
+func TestParseKeepsLastLineWithoutNewline(t *testing.T) {
+	r, err := Parse(strings.NewReader("VERSION: LiteSpeed Web Server/Open/1.6.18\nEOF\nBPS_IN: 1, BPS_OUT: 2"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1.0, r.GeneralInfo.KeyValues[BpsInField])
+	assert.Equal(t, 2.0, r.GeneralInfo.KeyValues[BpsOutField])
+}
+
+func TestParseFileParsesReport(t *testing.T) {
+	r, err := ParseFile(path.Join("..", "testdata", ".rtreport"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "LiteSpeed Web Server/Open/1.6.18", r.GeneralInfo.Version)
+	assert.Equal(t, "00:22:15", r.GeneralInfo.Uptime)
+	assert.Equal(t, 5.0, r.GeneralInfo.KeyValues[BpsInField])
+	assert.Equal(t, 0.0, r.GeneralInfo.KeyValues[BlockedIPField])
+	assert.Len(t, r.ReqRates, 4)
+	assert.Equal(t, "test.com", r.ReqRates[2].Hostname)
+	assert.Equal(t, 98670.0, r.ReqRates[2].KeyValues[ReqRateTotReqsField])
+	assert.Len(t, r.ExtApps, 3)
+	assert.Equal(t, "ABCDEFGH_php72:", r.ExtApps[2].Handler)
+
+	_, err = ParseFile(path.Join("..", "testdata", "non-existing-file"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestParserSkipsLinesAndFields(t *testing.T) {
+	p := Parser{
+		SkipHostReqRates: true,
+		SkipExtApps:      true,
+		SkipField: func(field string) bool {
+			return field == BpsOutField || field == ReqRateTotReqsField
+		},
+	}
+
+	r, err := p.ParseFile(path.Join("..", "testdata", ".rtreport"))
+
+	assert.Nil(t, err)
+	assert.NotContains(t, r.GeneralInfo.KeyValues, BpsOutField)
+	assert.Contains(t, r.GeneralInfo.KeyValues, BpsInField)
+	assert.Len(t, r.ReqRates, 1)
+	assert.Equal(t, "", r.ReqRates[0].Hostname)
+	assert.NotContains(t, r.ReqRates[0].KeyValues, ReqRateTotReqsField)
+	assert.Len(t, r.ExtApps, 0)
+}
+
+func TestParserSkipsHostsAndHandlers(t *testing.T) {
+	p := Parser{
+		SkipReqRate: func(hostname string) bool {
+			return hostname == "test.com"
+		},
+		SkipExtApp: func(service, hostname, handler string) bool {
+			return handler == "lsphp72"
+		},
+	}
+
+	r, err := p.ParseFile(path.Join("..", "testdata", ".rtreport"))
+
+	assert.Nil(t, err)
+	assert.Len(t, r.ReqRates, 3)
+	for _, rr := range r.ReqRates {
+		assert.NotEqual(t, "test.com", rr.Hostname)
+	}
+	assert.Len(t, r.ExtApps, 2)
+	for _, ea := range r.ExtApps {
+		assert.NotEqual(t, "lsphp72", ea.Handler)
+	}
+}
+
+func TestParserKeepsBlockedIPs(t *testing.T) {
+	report := "VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1\nBLOCKED_IP: 192.0.2.1, 192.0.2.2;T, 2001:db8::1\nEOF\n"
+
+	r, err := Parse(strings.NewReader(report))
+	assert.Nil(t, err)
+	assert.Nil(t, r.GeneralInfo.BlockedIPs)
+	assert.Equal(t, 3.0, r.GeneralInfo.KeyValues[BlockedIPField])
+
+	p := Parser{KeepBlockedIPs: true}
+	r, err = p.Parse(strings.NewReader(report))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2;T", "2001:db8::1"}, r.GeneralInfo.BlockedIPs)
+	assert.Equal(t, 3.0, r.GeneralInfo.KeyValues[BlockedIPField])
+}
+
+func TestParserReportsInvalidValues(t *testing.T) {
+	var errs []error
+	p := Parser{
+		Lenient: true,
+		ErrorHandler: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+
+	r, err := p.Parse(strings.NewReader("VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1.5, BPS_OUT: 2\nEOF\n"))
+
+	assert.Nil(t, err)
+	assert.NotContains(t, r.GeneralInfo.KeyValues, BpsInField)
+	assert.Equal(t, 2.0, r.GeneralInfo.KeyValues[BpsOutField])
+	assert.Len(t, errs, 1)
+
+	var pe *ParseError
+	assert.True(t, errors.As(errs[0], &pe))
+	assert.Equal(t, 2, pe.Line)
+	assert.Equal(t, "general", pe.Section)
+	assert.Equal(t, "invalid_value", pe.Reason)
+
+	var fe *FieldError
+	assert.True(t, errors.As(errs[0], &fe))
+	assert.Equal(t, BpsInField, fe.Field)
+	assert.Equal(t, "1.5", fe.Value)
+}
+
+// syntheticReport builds a report with the given number of virtual hosts,
+// each with its own request rates and external application
+func syntheticReport(vhosts int) []byte {
+	var b bytes.Buffer
+	b.WriteString("VERSION: LiteSpeed Web Server/Enterprise/6.0.12\nUPTIME: 12 days 01:02:03\n")
+	b.WriteString("BPS_IN: 5, BPS_OUT: 183, SSL_BPS_IN: 5, SSL_BPS_OUT: 819\n")
+	b.WriteString("MAXCONN: 10000, MAXSSL_CONN: 10000, PLAINCONN: 55, AVAILCONN: 9846, IDLECONN: 71, SSLCONN: 99, AVAILSSL: 9901\n")
+	b.WriteString("REQ_RATE []: REQ_PROCESSING: 3, REQ_PER_SEC: 120.5, TOT_REQS: 98765432, PUB_CACHE_HITS_PER_SEC: 10.1, TOTAL_PUB_CACHE_HITS: 1234, PRIVATE_CACHE_HITS_PER_SEC: 0.0, TOTAL_PRIVATE_CACHE_HITS: 0, STATIC_HITS_PER_SEC: 42.0, TOTAL_STATIC_HITS: 987654\n")
+	for i := 0; i < vhosts; i++ {
+		fmt.Fprintf(&b, "REQ_RATE [vhost%d.example.com]: REQ_PROCESSING: %d, REQ_PER_SEC: %d.3, TOT_REQS: %d, PUB_CACHE_HITS_PER_SEC: 0.1, TOTAL_PUB_CACHE_HITS: %d, PRIVATE_CACHE_HITS_PER_SEC: 0.0, TOTAL_PRIVATE_CACHE_HITS: 0, STATIC_HITS_PER_SEC: 1.5, TOTAL_STATIC_HITS: %d\n", i, i%5, i%100, i*1000, i*10, i*100)
+	}
+	for i := 0; i < vhosts; i++ {
+		fmt.Fprintf(&b, "EXTAPP [LSAPI] [vhost%d.example.com] [lsphp.%d]: CMAXCONN: 10, EMAXCONN: 10, POOL_SIZE: 1, INUSE_CONN: %d, IDLE_CONN: 1, WAITQUE_DEPTH: 0, REQ_PER_SEC: 0.5, TOT_REQS: %d\n", i, 10000+i, i%3, i*100)
+	}
+	b.WriteString("BLOCKED_IP: 192.0.2.1, 192.0.2.2, 198.51.100.7\nEOF\n")
+	return b.Bytes()
+}
+
+func TestParseParsesSyntheticReport(t *testing.T) {
+	r, err := Parse(bytes.NewReader(syntheticReport(100)))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "LiteSpeed Web Server/Enterprise/6.0.12", r.GeneralInfo.Version)
+	assert.Equal(t, "12 days 01:02:03", r.GeneralInfo.Uptime)
+	assert.Len(t, r.GeneralInfo.KeyValues, 12)
+	assert.Equal(t, 3.0, r.GeneralInfo.KeyValues[BlockedIPField])
+	assert.Len(t, r.ReqRates, 101)
+	assert.Equal(t, "vhost42.example.com", r.ReqRates[43].Hostname)
+	assert.Equal(t, 42000.0, r.ReqRates[43].KeyValues[ReqRateTotReqsField])
+	assert.Equal(t, 42.3, r.ReqRates[43].KeyValues[ReqRateReqPerSecField])
+	assert.Len(t, r.ExtApps, 100)
+	assert.Equal(t, "lsphp.10042", r.ExtApps[42].Handler)
+	assert.Equal(t, 4200.0, r.ExtApps[42].KeyValues[ExtappTotReqsField])
+}
+
+func benchmarkParse(b *testing.B, vhosts int) {
+	var p Parser
+	report := syntheticReport(vhosts)
+	r := bytes.NewReader(report)
+
+	b.SetBytes(int64(len(report)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.Reset(report)
+		if _, err := p.Parse(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParse100Vhosts(b *testing.B)  { benchmarkParse(b, 100) }
+func BenchmarkParse3000Vhosts(b *testing.B) { benchmarkParse(b, 3000) }
+
+func BenchmarkParseFile3000Vhosts(b *testing.B) {
+	f, err := ioutil.TempFile("", "BenchmarkParseFile")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	report := syntheticReport(3000)
+	f.Write(report)
+	f.Close()
+
+	var p Parser
+
+	b.SetBytes(int64(len(report)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := p.ParseFile(f.Name()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseKeyValues(b *testing.B) {
+	line := []byte("REQ_PROCESSING: 0, REQ_PER_SEC: 0.3, TOT_REQS: 98670, PUB_CACHE_HITS_PER_SEC: 0.0, TOTAL_PUB_CACHE_HITS: 0, PRIVATE_CACHE_HITS_PER_SEC: 0.0, TOTAL_PRIVATE_CACHE_HITS: 0, STATIC_HITS_PER_SEC: 0.0, TOTAL_STATIC_HITS: 6973")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for key, value, rest, ok := nextKeyValPair(line); ok; key, value, rest, ok = nextKeyValPair(rest) {
+			flag := fieldName(reqRateFieldNames, ReqRateField, key)
+			if _, err := parseValue(flag, value); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestParserRejectsInvalidValuesWhenStrict(t *testing.T) {
+	var errs []error
+	p := Parser{
+		ErrorHandler: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+
+	tests := []string{
+		"VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1.5, BPS_OUT: 2\nEOF\n",
+		"VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, BPS_OUT: 2\nREQ_RATE []: TOT_REQS: test\nEOF\n",
+		"VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, BPS_OUT: 2\nEXTAPP [LSAPI] [] [lsphp]: POOL_SIZE: -\nEOF\n",
+	}
+
+	for _, tc := range tests {
+		r, err := p.Parse(strings.NewReader(tc))
+
+		assert.Nil(t, r)
+		var pe *ParseError
+		assert.True(t, errors.As(err, &pe), "Unexpected error for report %q", tc)
+		assert.Equal(t, "invalid_value", pe.Reason)
+		var fe *FieldError
+		assert.True(t, errors.As(err, &fe))
+	}
+	assert.Empty(t, errs, "Errors failing the parse aren't handled")
+}
+
+func TestParserReportsMisplacedFields(t *testing.T) {
+	report := "VERSION: LiteSpeed Web Server/Open/1.6.18\nBPS_IN: 1, BPS_OUT: 2, REQ_RATE_TOT_REQS: 3\nMAXCONN: 10, EXTAPP_POOL_SIZE: 4\nEOF\n"
+
+	_, err := Parse(strings.NewReader(report))
+	var pe *ParseError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, 2, pe.Line)
+	assert.Equal(t, "misplaced_field", pe.Reason)
+
+	var errs []error
+	p := Parser{
+		Lenient: true,
+		ErrorHandler: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+	r, err := p.Parse(strings.NewReader(report))
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]float64{BpsInField: 1, BpsOutField: 2, MaxconnField: 10}, r.GeneralInfo.KeyValues)
+	assert.Len(t, errs, 2)
+}
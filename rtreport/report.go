@@ -0,0 +1,124 @@
+package rtreport
+
+// GeneralInfo holds the server wide fields of a report
+type GeneralInfo struct {
+	Version string
+	Uptime  string
+	// BlockedIPs lists the blocked addresses, only kept when Parser.KeepBlockedIPs is set
+	BlockedIPs []string
+	KeyValues  map[string]float64
+}
+
+// RequestRate holds the fields of a REQ_RATE line. The server wide line has
+// an empty Hostname.
+type RequestRate struct {
+	Hostname  string
+	KeyValues map[string]float64
+}
+
+// ExternalApp holds the fields of an EXTAPP line
+type ExternalApp struct {
+	Service   string
+	Hostname  string
+	Handler   string
+	KeyValues map[string]float64
+}
+
+// Report is the content of a single .rtreport file, or the merge of several
+type Report struct {
+	GeneralInfo GeneralInfo
+	ReqRates    []RequestRate
+	ExtApps     []ExternalApp
+}
+
+// NewReport returns an empty report
+func NewReport() *Report {
+	return &Report{
+		GeneralInfo: GeneralInfo{KeyValues: make(map[string]float64)},
+		ReqRates:    []RequestRate{},
+		ExtApps:     []ExternalApp{},
+	}
+}
+
+func (r *Report) indexOfReqRate(f func(rr RequestRate) bool) int {
+	for i, rrReport := range r.ReqRates {
+		if f(rrReport) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *Report) indexOfExtApp(f func(ea ExternalApp) bool) int {
+	for i, eaReport := range r.ExtApps {
+		if f(eaReport) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Add sums the fields of b into r. The version and uptime of b replace the
+// ones of r, and request rates and external applications missing from r are
+// appended to it. b is left untouched.
+func (r *Report) Add(b Report) {
+	if r.GeneralInfo.KeyValues == nil {
+		r.GeneralInfo.KeyValues = make(map[string]float64)
+	}
+
+	r.GeneralInfo.Version = b.GeneralInfo.Version
+	r.GeneralInfo.Uptime = b.GeneralInfo.Uptime
+	r.GeneralInfo.BlockedIPs = append(r.GeneralInfo.BlockedIPs, b.GeneralInfo.BlockedIPs...)
+	for flag, value := range b.GeneralInfo.KeyValues {
+		sumOrAppend(r.GeneralInfo.KeyValues, flag, value)
+	}
+
+	for _, rrReport := range b.ReqRates {
+		i := r.indexOfReqRate(func(rr RequestRate) bool {
+			return rr.Hostname == rrReport.Hostname
+		})
+
+		if i > -1 {
+			for k, v := range rrReport.KeyValues {
+				sumOrAppend(r.ReqRates[i].KeyValues, k, v)
+			}
+		} else {
+			rrReport.KeyValues = copyKeyValues(rrReport.KeyValues)
+			r.ReqRates = append(r.ReqRates, rrReport)
+		}
+	}
+
+	for _, eaReport := range b.ExtApps {
+		i := r.indexOfExtApp(func(ea ExternalApp) bool {
+			return ea.Service == eaReport.Service && ea.Hostname == eaReport.Hostname && ea.Handler == eaReport.Handler
+		})
+
+		if i > -1 {
+			for k, v := range eaReport.KeyValues {
+				sumOrAppend(r.ExtApps[i].KeyValues, k, v)
+			}
+		} else {
+			eaReport.KeyValues = copyKeyValues(eaReport.KeyValues)
+			r.ExtApps = append(r.ExtApps, eaReport)
+		}
+	}
+}
+
+// Merge sums the given reports, in order, into a new report. LiteSpeed writes
+// one report per worker process, so merging all of them gives the server
+// totals.
+func Merge(reports ...Report) *Report {
+	report := NewReport()
+	for _, r := range reports {
+		report.Add(r)
+	}
+	return report
+}
+
+func copyKeyValues(kv map[string]float64) map[string]float64 {
+	c := make(map[string]float64, len(kv))
+	for k, v := range kv {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,148 @@
+package rtreport
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexOfReqRateReturnsExpected(t *testing.T) {
+	lr := Report{
+		ReqRates: []RequestRate{
+			{Hostname: ""},
+			{Hostname: "Test 1"},
+			{Hostname: "Test 2"},
+		},
+	}
+
+	tests := []struct {
+		hostname string
+		want     int
+	}{
+		{"", 0},
+		{"Test 1", 1},
+		{"Test 2", 2},
+		{"Non existing", -1},
+	}
+
+	for _, tc := range tests {
+		f := func(r RequestRate) bool {
+			return strings.HasPrefix(r.Hostname, tc.hostname)
+		}
+		assert.Equal(t, tc.want, lr.indexOfReqRate(f), "Unexpected filtered reports.")
+	}
+}
+
+func TestIndexOfExtappReturnsExpected(t *testing.T) {
+	lr := Report{
+		ExtApps: []ExternalApp{
+			{Service: "Proxy", Hostname: "localhost", Handler: "lsphp.10000"},
+			{Service: "LSAPI", Hostname: "localhost", Handler: "lsphp.10001"},
+			{Service: "LSAPI", Hostname: "test.com", Handler: "lsphp.10002"},
+		},
+	}
+
+	tests := []struct {
+		service  string
+		hostname string
+		want     int
+	}{
+		{"Proxy", "localhost", 0},
+		{"LSAPI", "localhost", 1},
+		{"LSAPI", "test.com", 2},
+		{"Proxy", "test.com", -1},
+	}
+
+	for _, tc := range tests {
+		f := func(r ExternalApp) bool {
+			return r.Service == tc.service && r.Hostname == tc.hostname
+		}
+		assert.Equal(t, tc.want, lr.indexOfExtApp(f), "Unexpected filtered reports.")
+	}
+}
+
+func TestAddsReportsProperly(t *testing.T) {
+	a := Report{
+		GeneralInfo: GeneralInfo{Version: "Test version", Uptime: "00:10:10", KeyValues: map[string]float64{BpsInField: 11}},
+		ReqRates: []RequestRate{
+			{Hostname: "", KeyValues: map[string]float64{ReqRateReqPerSecField: 12.34, ReqRateTotReqsField: 5}},
+		},
+		ExtApps: []ExternalApp{
+			{Service: "Proxy", Hostname: "", Handler: "lsphp.10000", KeyValues: map[string]float64{}},
+		},
+	}
+
+	b := Report{
+		GeneralInfo: GeneralInfo{Version: "Test version 2", Uptime: "00:20:20", KeyValues: map[string]float64{BpsInField: 123, BpsOutField: 321}},
+		ReqRates: []RequestRate{
+			{Hostname: "", KeyValues: map[string]float64{ReqRateReqPerSecField: 10, ReqRateTotReqsField: 6}},
+			{Hostname: "Test 1", KeyValues: map[string]float64{ReqRateTotalPubCacheHitsField: 200}},
+		},
+		ExtApps: []ExternalApp{
+			{Service: "Proxy", Hostname: "", Handler: "lsphp.10000", KeyValues: map[string]float64{ExtappReqPerSecField: 7.8, ExtappTotReqsField: 456}},
+			{Service: "LSAPI", Hostname: "localhost", Handler: "lsphp.10001", KeyValues: map[string]float64{ExtappCmaxconnField: 1000, ExtappEmaxconnField: 1678}},
+		},
+	}
+
+	a.Add(b)
+
+	assert.Equal(t, b.GeneralInfo.Version, a.GeneralInfo.Version)
+	assert.Equal(t, b.GeneralInfo.Uptime, a.GeneralInfo.Uptime)
+	assert.Len(t, a.GeneralInfo.KeyValues, 2)
+	assert.Equal(t, 134.0, a.GeneralInfo.KeyValues[BpsInField])
+	assert.Equal(t, 321.0, a.GeneralInfo.KeyValues[BpsOutField])
+	assert.Len(t, a.ReqRates, 2)
+	assert.Equal(t, "", a.ReqRates[0].Hostname)
+	assert.Equal(t, 22.34, a.ReqRates[0].KeyValues[ReqRateReqPerSecField])
+	assert.Equal(t, 11.0, a.ReqRates[0].KeyValues[ReqRateTotReqsField])
+	assert.Equal(t, b.ReqRates[1], a.ReqRates[1])
+	assert.Len(t, a.ExtApps, 2)
+	assert.Equal(t, "Proxy", a.ExtApps[0].Service)
+	assert.Equal(t, "", a.ExtApps[0].Hostname)
+	assert.Equal(t, "lsphp.10000", a.ExtApps[0].Handler)
+	assert.Len(t, a.ExtApps[0].KeyValues, 2)
+	assert.Equal(t, 7.8, a.ExtApps[0].KeyValues[ExtappReqPerSecField])
+	assert.Equal(t, 456.0, a.ExtApps[0].KeyValues[ExtappTotReqsField])
+	assert.Equal(t, b.ExtApps[1], a.ExtApps[1])
+}
+
+func TestAddLeavesItsArgumentUntouched(t *testing.T) {
+	a := NewReport()
+	b := Report{
+		GeneralInfo: GeneralInfo{KeyValues: map[string]float64{BpsInField: 1}},
+		ReqRates: []RequestRate{
+			{Hostname: "Test 1", KeyValues: map[string]float64{ReqRateTotReqsField: 2}},
+		},
+		ExtApps: []ExternalApp{
+			{Service: "LSAPI", Hostname: "localhost", Handler: "lsphp.10001", KeyValues: map[string]float64{ExtappTotReqsField: 3}},
+		},
+	}
+
+	a.Add(b)
+	a.Add(b)
+
+	assert.Equal(t, 2.0, a.GeneralInfo.KeyValues[BpsInField])
+	assert.Equal(t, 4.0, a.ReqRates[0].KeyValues[ReqRateTotReqsField])
+	assert.Equal(t, 6.0, a.ExtApps[0].KeyValues[ExtappTotReqsField])
+	assert.Equal(t, 1.0, b.GeneralInfo.KeyValues[BpsInField])
+	assert.Equal(t, 2.0, b.ReqRates[0].KeyValues[ReqRateTotReqsField])
+	assert.Equal(t, 3.0, b.ExtApps[0].KeyValues[ExtappTotReqsField])
+}
+
+func TestMergeSumsReportsInOrder(t *testing.T) {
+	a := Report{
+		GeneralInfo: GeneralInfo{Version: "Test version", Uptime: "00:10:10", KeyValues: map[string]float64{BpsInField: 11}},
+	}
+	b := Report{
+		GeneralInfo: GeneralInfo{Version: "Test version 2", Uptime: "00:20:20", KeyValues: map[string]float64{BpsInField: 123}},
+	}
+
+	r := Merge(a, b)
+
+	assert.Equal(t, b.GeneralInfo.Version, r.GeneralInfo.Version)
+	assert.Equal(t, b.GeneralInfo.Uptime, r.GeneralInfo.Uptime)
+	assert.Equal(t, 134.0, r.GeneralInfo.KeyValues[BpsInField])
+	assert.Equal(t, 11.0, a.GeneralInfo.KeyValues[BpsInField])
+	assert.Equal(t, NewReport(), Merge())
+}
@@ -0,0 +1,161 @@
+package rtreport
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func sumOrAppend(kv map[string]float64, k string, v float64) {
+	if _, ok := kv[k]; ok {
+		kv[k] += v
+	} else {
+		kv[k] = v
+	}
+}
+
+// parseValue parses the value of a field, integer fields being rejected when
+// they hold a float
+func parseValue(flag string, value []byte) (float64, error) {
+	switch flag {
+	case ReqRateReqPerSecField, ReqRatePubCacheHitsPerSecField, ReqRatePrivateCacheHitsPerSecField, ReqRateStaticHitsPerSecField, ExtappReqPerSecField:
+		return strconv.ParseFloat(string(value), 64)
+	case BpsInField, BpsOutField, SslBpsInField, SslBpsOutField, MaxconnField, MaxsslConnField, PlainconnField, AvailconnField, IdleconnField, SslconnField, AvailsslField,
+		ReqRateReqProcessingField, ReqRateTotReqsField, ReqRateTotalPubCacheHitsField, ReqRateTotalPrivateCacheHitsField, ReqRateTotalStaticHitsField,
+		ExtappCmaxconnField, ExtappEmaxconnField, ExtappPoolSizeField, ExtappInuseConnField, ExtappIdleConnField, ExtappWaitqueDepthField, ExtappTotReqsField:
+		valueInt, err := parseInt(value)
+		if err != nil {
+			return 0, err
+		}
+		return float64(valueInt), nil
+	default:
+		// Fields unknown to this package may hold either integers or floats
+		return strconv.ParseFloat(string(value), 64)
+	}
+}
+
+// parseInt parses a base 10 integer without going through a string
+func parseInt(b []byte) (int64, error) {
+	s := b
+	neg := false
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if len(s) == 0 {
+		return 0, &strconv.NumError{Func: "ParseInt", Num: string(b), Err: strconv.ErrSyntax}
+	}
+
+	var n int64
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return 0, &strconv.NumError{Func: "ParseInt", Num: string(b), Err: strconv.ErrSyntax}
+		}
+		d := int64(ch - '0')
+		if n > (math.MaxInt64-d)/10 {
+			return 0, &strconv.NumError{Func: "ParseInt", Num: string(b), Err: strconv.ErrRange}
+		}
+		n = n*10 + d
+	}
+
+	if neg {
+		return -n, nil
+	}
+	return n, nil
+}
+
+// ParseUptime converts the UPTIME value written by LiteSpeed to a duration.
+// Supported formats are "HH:MM:SS", "MM:SS", "D:HH:MM:SS", a day prefix such
+// as "2 days 03:04:05", "1 day, 03:04:05" or "2d 03:04:05", and plain seconds.
+func ParseUptime(value string) (time.Duration, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return 0, fmt.Errorf("empty uptime")
+	}
+
+	var days int64
+	hasDays := false
+	if fields := strings.Fields(s); len(fields) > 1 {
+		var d string
+		switch {
+		case len(fields) == 3 && strings.HasPrefix(strings.ToLower(fields[1]), "day"):
+			d = fields[0]
+		case len(fields) == 2 && strings.HasSuffix(strings.ToLower(fields[0]), "d"):
+			d = fields[0][:len(fields[0])-1]
+		default:
+			return 0, fmt.Errorf("unknown uptime format %q", value)
+		}
+
+		n, err := strconv.ParseInt(d, 10, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid uptime days in %q", value)
+		}
+		days, hasDays = n, true
+		s = fields[len(fields)-1]
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 4 || (hasDays && len(parts) == 4) {
+		return 0, fmt.Errorf("unknown uptime format %q", value)
+	}
+
+	// Multipliers for seconds, minutes, hours and days, from the right.
+	multipliers := []int64{1, 60, 3600, 86400}
+	seconds := days * 86400
+	for i := range parts {
+		n, err := strconv.ParseInt(parts[len(parts)-1-i], 10, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid uptime %q", value)
+		}
+		seconds += n * multipliers[i]
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// parseBlockedIPs splits the value of a BLOCKED_IP line into its entries
+func parseBlockedIPs(value []byte) []string {
+	var ips []string
+	for ip, rest, ok := nextBlockedIP(value); ok; ip, rest, ok = nextBlockedIP(rest) {
+		ips = append(ips, string(ip))
+	}
+	return ips
+}
+
+// countBlockedIPs counts the entries of a BLOCKED_IP line value without
+// splitting it
+func countBlockedIPs(value []byte) int {
+	n := 0
+	for _, rest, ok := nextBlockedIP(value); ok; _, rest, ok = nextBlockedIP(rest) {
+		n++
+	}
+	return n
+}
+
+// nextBlockedIP returns the first entry of a BLOCKED_IP line value and the
+// remainder of the value, entries being separated by commas and spaces
+func nextBlockedIP(value []byte) (ip, rest []byte, ok bool) {
+	start := 0
+	for start < len(value) && isBlockedIPSeparator(value[start]) {
+		start++
+	}
+	if start == len(value) {
+		return nil, nil, false
+	}
+
+	end := start
+	for end < len(value) && !isBlockedIPSeparator(value[end]) {
+		end++
+	}
+	return value[start:end], value[end:], true
+}
+
+func isBlockedIPSeparator(ch byte) bool {
+	switch ch {
+	case ',', ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package rtreport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSumOrAppendHandlesProperly(t *testing.T) {
+	tests := []struct {
+		kv map[string]float64
+		k  string
+		v  float64
+		e  map[string]float64
+	}{
+		{map[string]float64{}, "test", 0.1, map[string]float64{"test": 0.1}},
+		{map[string]float64{"test2": 1.2}, "test2", 0.8, map[string]float64{"test2": 2.0}},
+	}
+
+	for _, tc := range tests {
+		sumOrAppend(tc.kv, tc.k, tc.v)
+		assert.Equal(t, tc.e, tc.kv)
+	}
+}
+
+func TestParseValueParsesValidValueProperly(t *testing.T) {
+	tests := []struct {
+		f string
+		v string
+		e float64
+	}{
+		{ReqRateReqPerSecField, "0.1", 0.1},
+		{ExtappReqPerSecField, "1.2", 1.2},
+		{ReqRatePubCacheHitsPerSecField, "2.34", 2.34},
+		{ReqRatePrivateCacheHitsPerSecField, "4.5", 4.5},
+		{ReqRateStaticHitsPerSecField, "5.6", 5.6},
+		{BpsInField, "50", 50.0},
+		{PlainconnField, "123", 123.0},
+		{"REQ_RATE_NEW_FIELD", "1.5", 1.5},
+		{"CACHE_SIZE", "1024", 1024.0},
+	}
+
+	for _, tc := range tests {
+		value, err := parseValue(tc.f, []byte(tc.v))
+		assert.Equal(t, tc.e, value)
+		assert.Nil(t, err, "Unexpected error while parsing value")
+	}
+}
+
+func TestParseValueHandlesInvalidValueType(t *testing.T) {
+	tests := []struct {
+		f string
+		v string
+	}{
+		{VersionField, "test"},
+		{BpsInField, "1.5"},
+	}
+
+	for _, tc := range tests {
+		value, err := parseValue(tc.f, []byte(tc.v))
+		assert.Equal(t, 0.0, value)
+		assert.Error(t, err)
+	}
+}
+
+func TestParseIntReturnsExpected(t *testing.T) {
+	tests := []struct {
+		s  string
+		e  int64
+		ok bool
+	}{
+		{"0", 0, true},
+		{"98670", 98670, true},
+		{"-12", -12, true},
+		{"+7", 7, true},
+		{"9223372036854775807", 9223372036854775807, true},
+		{"9223372036854775808", 0, false},
+		{"", 0, false},
+		{"-", 0, false},
+		{"1.1", 0, false},
+		{"test", 0, false},
+	}
+
+	for _, tc := range tests {
+		n, err := parseInt([]byte(tc.s))
+		assert.Equal(t, tc.e, n)
+		assert.Equal(t, tc.ok, err == nil, "Unexpected error for %q: %v", tc.s, err)
+	}
+}
+
+func TestParseUptimeParsesKnownFormats(t *testing.T) {
+	tests := []struct {
+		s string
+		e time.Duration
+	}{
+		{"00:22:15", 1335 * time.Second},
+		{"123:00:01", 442801 * time.Second},
+		{"05:07", 307 * time.Second},
+		{"1:02:03:04", 93784 * time.Second},
+		{"1 day 00:00:01", 86401 * time.Second},
+		{"36 days 00:10:15", 3111015 * time.Second},
+		{"2 days, 01:00:00", 176400 * time.Second},
+		{"3d 00:00:10", 259210 * time.Second},
+		{"42", 42 * time.Second},
+	}
+
+	for _, tc := range tests {
+		v, err := ParseUptime(tc.s)
+		assert.Nil(t, err, "Unexpected error while parsing uptime %q", tc.s)
+		assert.Equal(t, tc.e, v)
+	}
+}
+
+func TestParseUptimeHandlesInvalidFormats(t *testing.T) {
+	tests := []string{"", "test", "00:aa:00", "1 week 00:00:00", "1 day 1:00:00:00", "-1:00:00", "1:2:3:4:5"}
+
+	for _, tc := range tests {
+		v, err := ParseUptime(tc)
+		assert.Equal(t, time.Duration(0), v)
+		assert.Error(t, err, "Expected error while parsing uptime %q", tc)
+	}
+}
+
+func TestParseBlockedIPsReturnsExpected(t *testing.T) {
+	tests := []struct {
+		s string
+		e []string
+	}{
+		{"", []string{}},
+		{" ", []string{}},
+		{" 192.0.2.1", []string{"192.0.2.1"}},
+		{" 192.0.2.1, 192.0.2.2,", []string{"192.0.2.1", "192.0.2.2"}},
+		{" 192.0.2.1;T 2001:db8::1", []string{"192.0.2.1;T", "2001:db8::1"}},
+		{" 192.0.2.1,\t192.0.2.2\r", []string{"192.0.2.1", "192.0.2.2"}},
+		{" 192.0.2.1\u00a0192.0.2.2", []string{"192.0.2.1\u00a0192.0.2.2"}},
+	}
+
+	for _, tc := range tests {
+		assert.ElementsMatch(t, tc.e, parseBlockedIPs([]byte(tc.s)))
+		assert.Equal(t, len(tc.e), countBlockedIPs([]byte(tc.s)))
+	}
+}
@@ -0,0 +1,52 @@
+package rtreport
+
+import "strings"
+
+// Version is the VERSION line of a report split into its parts, such as
+// "LiteSpeed Web Server/Enterprise/6.0.12"
+type Version struct {
+	Product string
+	// Edition is one of Open, Standard or Enterprise, empty when the line has no edition
+	Edition string
+	Version string
+	Major   string
+	Minor   string
+}
+
+// ParseVersion splits the VERSION line of a report. Parts that can't be
+// found are left empty.
+func ParseVersion(value string) Version {
+	parts := strings.Split(strings.TrimSpace(value), "/")
+
+	var v Version
+	switch len(parts) {
+	case 1:
+		v.Product = parts[0]
+	case 2:
+		v.Product, v.Version = parts[0], parts[1]
+	default:
+		v.Product, v.Edition, v.Version = parts[0], parts[1], parts[len(parts)-1]
+	}
+
+	numbers := strings.SplitN(v.Version, ".", 3)
+	if isNumber(numbers[0]) {
+		v.Major = numbers[0]
+		if len(numbers) > 1 && isNumber(numbers[1]) {
+			v.Minor = numbers[1]
+		}
+	}
+
+	return v
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
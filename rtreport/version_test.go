@@ -0,0 +1,27 @@
+package rtreport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersionReturnsExpected(t *testing.T) {
+	tests := []struct {
+		s string
+		e Version
+	}{
+		{"LiteSpeed Web Server/Open/1.6.18", Version{"LiteSpeed Web Server", "Open", "1.6.18", "1", "6"}},
+		{"LiteSpeed Web Server/Enterprise/6.0.12", Version{"LiteSpeed Web Server", "Enterprise", "6.0.12", "6", "0"}},
+		{"LiteSpeed Web Server/Standard/5.4", Version{"LiteSpeed Web Server", "Standard", "5.4", "5", "4"}},
+		{"LiteSpeed Web Server/5.3.8", Version{"LiteSpeed Web Server", "", "5.3.8", "5", "3"}},
+		{"LiteSpeed Web Server/Open/6", Version{"LiteSpeed Web Server", "Open", "6", "6", ""}},
+		{"LiteSpeed Web Server/Open/dev", Version{"LiteSpeed Web Server", "Open", "dev", "", ""}},
+		{"LiteSpeed", Version{"LiteSpeed", "", "", "", ""}},
+		{"", Version{}},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.e, ParseVersion(tc.s), "Unexpected version for %q", tc.s)
+	}
+}